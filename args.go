@@ -0,0 +1,202 @@
+package kongcue
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"cuelang.org/go/cue"
+	"github.com/alecthomas/kong"
+)
+
+// CommandLine returns the command line equivalent to the parsed command
+// line combined with the config: the application name, the selected
+// commands and positional arguments, and a flag for every value set on the
+// command line or resolved from config. Config values are passed exactly as
+// the resolver passes them to Kong, so relative paths are already resolved
+// against their config file. Defaults and environment variables are not
+// included.
+//
+// ctx must have been resolved (e.g. in a BeforeApply hook or after Parse)
+// and config is the value bound by kongcue.Config.
+func CommandLine(ctx *kong.Context, config cue.Value) ([]string, error) {
+	opts := schemaOptionsFrom(ctx).toInternal()
+	resolver := &cueResolver{value: config, opts: opts}
+	args := []string{ctx.Model.Name}
+
+	for _, trace := range ctx.Path {
+		switch {
+		case trace.App != nil:
+		case trace.Command != nil:
+			args = append(args, trace.Command.Name)
+		case trace.Positional != nil:
+			args = append(args, positionalArgs(ctx.Value(trace).Interface(), trace.Positional.Format, opts)...)
+			continue
+		case trace.Argument != nil:
+			args = append(args, positionalArgs(ctx.Value(trace).Interface(), "", opts)...)
+		default:
+			continue
+		}
+
+		for _, flag := range trace.Node().Flags {
+			if !isConfigFlag(flag) {
+				continue
+			}
+			value, ok, err := commandLineValue(ctx, resolver, trace, flag)
+			if err != nil {
+				return nil, fmt.Errorf("--%s: %w", flag.Name, err)
+			}
+			if ok {
+				args = append(args, flagArgs(flag, value)...)
+			}
+		}
+	}
+	return args, nil
+}
+
+// commandLineValue returns the value a flag was given, either on the
+// command line (as a native config value) or from config (as passed to
+// Kong by the resolver). Returns false if the flag wasn't set by either.
+func commandLineValue(ctx *kong.Context, resolver *cueResolver, parent *kong.Path, flag *kong.Flag) (any, bool, error) {
+	for _, trace := range ctx.Path {
+		if trace.Flag != flag {
+			continue
+		}
+		if !trace.Resolved {
+			return goToConfigValue(ctx.FlagValue(flag), flag.Format, resolver.opts), true, nil
+		}
+		value, err := resolver.Resolve(ctx, parent, flag)
+		return value, value != nil, err
+	}
+	return nil, false, nil
+}
+
+// flagArgs formats a flag and its value as command line arguments.
+// Booleans become --flag or --no-flag (when negatable), slices and maps are
+// joined with the flag's separators, or repeated if it has none.
+func flagArgs(flag *kong.Flag, value any) []string {
+	name := "--" + flag.Name
+	switch v := value.(type) {
+	case bool:
+		if v {
+			return []string{name}
+		}
+		switch flag.Tag.Negatable {
+		case "":
+			return []string{name + "=false"}
+		case "_":
+			return []string{"--no-" + flag.Name}
+		default:
+			return []string{"--" + flag.Tag.Negatable}
+		}
+	case []any:
+		items := make([]string, len(v))
+		for i, item := range v {
+			items[i] = fmt.Sprint(item)
+		}
+		return joinedFlagArgs(name, items, flag.Tag.Sep)
+	case map[string]any:
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		entries := make([]string, len(keys))
+		for i, k := range keys {
+			entries[i] = k + "=" + fmt.Sprint(v[k])
+		}
+		return joinedFlagArgs(name, entries, flag.Tag.MapSep)
+	default:
+		return []string{name + "=" + fmt.Sprint(v)}
+	}
+}
+
+// joinedFlagArgs passes items as a single flag joined with sep, or as one
+// flag per item if the flag has no separator or an item can't be joined.
+func joinedFlagArgs(name string, items []string, sep rune) []string {
+	if len(items) == 0 {
+		return nil
+	}
+	if sep != -1 && joinable(items) {
+		return []string{name + "=" + kong.JoinEscaped(items, sep)}
+	}
+	args := make([]string, len(items))
+	for i, item := range items {
+		args[i] = name + "=" + item
+	}
+	return args
+}
+
+// positionalArgs formats the value of a positional argument, which may be
+// a slice for variadic arguments. format is its format:"..." tag.
+func positionalArgs(value any, format string, opts *schemaOptions) []string {
+	switch v := goToConfigValue(value, format, opts).(type) {
+	case nil:
+		return nil
+	case []any:
+		args := make([]string, len(v))
+		for i, item := range v {
+			args[i] = fmt.Sprint(item)
+		}
+		return args
+	default:
+		return []string{fmt.Sprint(v)}
+	}
+}
+
+// shellQuote quotes an argument for POSIX shells if it contains anything
+// but safe characters.
+func shellQuote(arg string) string {
+	if arg == "" {
+		return "''"
+	}
+	safe := true
+	for _, r := range arg {
+		if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || strings.ContainsRune("-_./=:,@%+", r)) {
+			safe = false
+			break
+		}
+	}
+	if safe {
+		return arg
+	}
+	return "'" + strings.ReplaceAll(arg, "'", `'\''`) + "'"
+}
+
+// ShellJoin joins arguments into a string that a POSIX shell splits back
+// into the same arguments.
+func ShellJoin(args []string) string {
+	quoted := make([]string, len(args))
+	for i, arg := range args {
+		quoted[i] = shellQuote(arg)
+	}
+	return strings.Join(quoted, " ")
+}
+
+// PrintArgs is a flag that prints the command line equivalent to the
+// current command line and config files, then exits. Useful for bug
+// reports: the printed command reproduces the run without the config.
+//
+// Usage:
+//
+//	type cli struct {
+//	    Config    kongcue.Config    `default:"~/.myapp.yaml"`
+//	    PrintArgs kongcue.PrintArgs `help:"Print the equivalent command line and exit."`
+//	    Agent     agentCmd          `cmd:""`
+//	}
+//
+// Running `./myapp agent --print-args` prints something like
+// `myapp agent --ca-url=https://ca.example.com --port=8443`.
+type PrintArgs bool
+
+// BeforeApply prints the command line. It runs after config values have
+// been resolved, before required flags are checked.
+func (p PrintArgs) BeforeApply(app *kong.Kong, ctx *kong.Context, config cue.Value) error {
+	args, err := CommandLine(ctx, config)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(app.Stdout, ShellJoin(args))
+	app.Exit(0)
+	return nil
+}
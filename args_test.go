@@ -0,0 +1,175 @@
+package kongcue_test
+
+import (
+	"bytes"
+	"net"
+	"net/url"
+	"reflect"
+	"testing"
+	"time"
+
+	"cuelang.org/go/cue"
+	"github.com/alecthomas/kong"
+	kongcue "github.com/brianm/kongcue"
+)
+
+type argsCLI struct {
+	Verbose   int               `short:"v" type:"counter"`
+	Color     bool              `negatable:""`
+	Name      string            `default:"world"`
+	PrintArgs kongcue.PrintArgs `name:"print-args"`
+	Config    kongcue.Config    `default:"./config.yaml"`
+	Agent     struct {
+		Hosts   []string          `name:"hosts"`
+		Raw     []string          `sep:"none"`
+		Labels  map[string]string `name:"labels"`
+		Timeout time.Duration     `name:"timeout"`
+		Target  string            `arg:"" optional:""`
+	} `cmd:""`
+}
+
+func TestCommandLine(t *testing.T) {
+	dir := t.TempDir()
+	config := kongcue.WriteConfig(t, dir, "config.yaml", ""+
+		"verbose: 2\n"+
+		"agent:\n"+
+		"  hosts: [\"a,b\", c]\n"+
+		"  raw: [x, y]\n"+
+		"  labels: {env: prod, team: core}\n"+
+		"  timeout: 5s\n")
+
+	var cli argsCLI
+	parser, err := kong.New(&cli, kongcue.Options())
+	if err != nil {
+		t.Fatalf("failed to create parser: %v", err)
+	}
+	ctx, err := parser.Parse([]string{"--config", config, "--no-color", "agent", "host1"})
+	if err != nil {
+		t.Fatalf("failed to parse: %v", err)
+	}
+	value, err := kongcue.LoadAndUnifyPaths([]string{config})
+	if err != nil {
+		t.Fatalf("failed to load config: %v", err)
+	}
+
+	args, err := kongcue.CommandLine(ctx, value)
+	if err != nil {
+		t.Fatalf("failed to build command line: %v", err)
+	}
+	expected := []string{
+		"kongcue.test",
+		"--verbose=2",
+		"--no-color",
+		"agent",
+		`--hosts=a\,b,c`,
+		"--raw=x",
+		"--raw=y",
+		"--labels=env=prod;team=core",
+		"--timeout=5s",
+		"host1",
+	}
+	if !reflect.DeepEqual(args, expected) {
+		t.Errorf("got  %q\nwant %q", args, expected)
+	}
+
+	// The command line must reproduce the same values without the config
+	var replay argsCLI
+	parser, err = kong.New(&replay, kongcue.Options())
+	if err != nil {
+		t.Fatalf("failed to create parser: %v", err)
+	}
+	t.Chdir(t.TempDir())
+	if _, err := parser.Parse(args[1:]); err != nil {
+		t.Fatalf("failed to parse command line: %v", err)
+	}
+	replay.Config, cli.Config = nil, nil
+	if !reflect.DeepEqual(replay, cli) {
+		t.Errorf("replayed command line differs:\ngot  %+v\nwant %+v", replay, cli)
+	}
+}
+
+type typedArgsCLI struct {
+	Config   kongcue.Config `default:"./config.yaml"`
+	Since    time.Time      `name:"since"`
+	Day      time.Time      `name:"day" format:"2006-01-02"`
+	Endpoint url.URL        `name:"endpoint"`
+	Bind     net.IP         `name:"bind"`
+	Peers    []net.IP       `name:"peers"`
+	At       time.Time      `arg:"" optional:"" format:"15:04"`
+}
+
+func TestCommandLine_TypeMappings(t *testing.T) {
+	t.Chdir(t.TempDir())
+	var cli typedArgsCLI
+	parser, err := kong.New(&cli, kongcue.Options())
+	if err != nil {
+		t.Fatalf("failed to create parser: %v", err)
+	}
+	ctx, err := parser.Parse([]string{
+		"--since", "2024-03-01T12:30:00+02:00",
+		"--day", "2024-03-01",
+		"--endpoint", "https://ca.test/v1?x=1",
+		"--bind", "10.0.0.1",
+		"--peers", "10.0.0.2,::1",
+		"09:45",
+	})
+	if err != nil {
+		t.Fatalf("failed to parse: %v", err)
+	}
+
+	args, err := kongcue.CommandLine(ctx, cue.Value{})
+	if err != nil {
+		t.Fatalf("failed to build command line: %v", err)
+	}
+	expected := []string{
+		"kongcue.test",
+		"--since=2024-03-01T12:30:00+02:00",
+		"--day=2024-03-01",
+		"--endpoint=https://ca.test/v1?x=1",
+		"--bind=10.0.0.1",
+		"--peers=10.0.0.2,::1",
+		"09:45",
+	}
+	if !reflect.DeepEqual(args, expected) {
+		t.Errorf("got  %q\nwant %q", args, expected)
+	}
+}
+
+func TestShellJoin(t *testing.T) {
+	got := kongcue.ShellJoin([]string{"app", "--name=plain", "--msg=it's here", ""})
+	want := `app --name=plain '--msg=it'\''s here' ''`
+	if got != want {
+		t.Errorf("got %s, want %s", got, want)
+	}
+}
+
+func TestPrintArgs(t *testing.T) {
+	dir := t.TempDir()
+	config := kongcue.WriteConfig(t, dir, "config.yaml", "name: \"Jane Doe\"\n")
+
+	var cli argsCLI
+	var stdout bytes.Buffer
+	exited := -1
+	parser, err := kong.New(&cli, kongcue.Options(),
+		kong.Name("app"),
+		kong.Writers(&stdout, &stdout),
+		kong.Exit(func(code int) {
+			exited = code
+			panic("exit")
+		}))
+	if err != nil {
+		t.Fatalf("failed to create parser: %v", err)
+	}
+
+	func() {
+		defer func() { recover() }()
+		parser.Parse([]string{"--config", config, "agent", "--print-args"})
+	}()
+
+	if exited != 0 {
+		t.Errorf("expected exit status 0, got %d", exited)
+	}
+	if want := "app '--name=Jane Doe' agent\n"; stdout.String() != want {
+		t.Errorf("got %q, want %q", stdout.String(), want)
+	}
+}
@@ -1,10 +1,17 @@
 package kongcue
 
 import (
+	"bytes"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"io/fs"
 	"os"
+	"path/filepath"
+	"strings"
 
+	"cuelang.org/go/cue/ast"
 	"cuelang.org/go/cue/format"
 	"github.com/alecthomas/kong"
 )
@@ -13,7 +20,9 @@ import (
 // Embed this in your CLI struct to add a command that prints the config schema.
 //
 // The generated schema uses named CUE definitions (#Root, #CommandName, etc.)
-// and can be used to validate configuration files.
+// and can be used to validate configuration files. Other formats are
+// available with --format: JSON Schema, an example YAML config, or Markdown
+// reference documentation.
 //
 // Usage:
 //
@@ -22,41 +31,265 @@ import (
 //	    ConfigDoc kongcue.ConfigDoc `cmd:"config-doc" help:"Print CUE schema for config file"`
 //	}
 //
-// Running `./myapp config-doc` outputs the schema to stdout.
+// Running `./myapp config-doc` outputs the schema to stdout, and
+// `./myapp config-doc --format markdown --command agent --output agent.md`
+// documents only the agent command's section in a file.
 type ConfigDoc struct {
-	// Output is the writer for schema output. Defaults to os.Stdout.
-	// Exposed for testing.
+	Format  string `help:"Output format (${enum})." enum:"cue,jsonschema,yaml-example,markdown" default:"cue"`
+	Command string `help:"Only document the config section of this command (e.g. agent.tls)." placeholder:"PATH"`
+	File    string `name:"output" help:"Write to this file instead of stdout." type:"path" placeholder:"FILE"`
+
+	// Output is the writer for schema output. Defaults to the Kong
+	// application's stdout. Exposed for testing; when set, the command
+	// returns instead of exiting.
 	Output io.Writer `kong:"-"`
 }
 
+// SkipsConfig reports that the config isn't loaded for config-doc, which
+// only describes it.
+func (ConfigDoc) SkipsConfig() bool {
+	return true
+}
+
 // BeforeApply is called by Kong before validation. Using BeforeApply (instead of
 // AfterApply) allows this command to run without requiring other flags to be set,
-// similar to --help.
-func (c *ConfigDoc) BeforeApply(app *kong.Kong, schemaOpts *SchemaOptions) error {
+// similar to --help. Because flags are not yet applied to the struct at this
+// point, the command's own flags are read from the parse context.
+func (c *ConfigDoc) BeforeApply(app *kong.Kong, ctx *kong.Context, schemaOpts *SchemaOptions) error {
 	// Get schema options (set via AllowUnknownFields)
 	opts := schemaOpts.toInternal()
 
-	file := GenerateSchemaFile(app.Model, opts)
+	if err := checkConstraintTags(app.Model.Node); err != nil {
+		return err
+	}
 
-	src, err := format.Node(file)
+	docFormat := flagString(ctx, "format", c.Format)
+	command := flagString(ctx, "command", c.Command)
+	file := flagString(ctx, "output", c.File)
+
+	src, err := renderConfigDoc(app.Model, opts, docFormat, command)
 	if err != nil {
-		return fmt.Errorf("failed to format schema: %w", err)
+		return err
+	}
+
+	if file != "" {
+		if err := os.WriteFile(file, src, 0o644); err != nil {
+			return err
+		}
+	} else {
+		out := c.Output
+		if out == nil {
+			out = app.Stdout
+		}
+		if _, err := out.Write(src); err != nil {
+			return err
+		}
+	}
+
+	// Exit cleanly without running validation or the command.
+	// If Output is set (testing), don't exit so tests can check the output.
+	if c.Output == nil {
+		app.Exit(0)
+	}
+	return nil
+}
+
+// flagString returns the value of the selected command's flag with the given
+// name from the parse context, or fallback if the flag isn't found.
+func flagString(ctx *kong.Context, name, fallback string) string {
+	if s, ok := flagValue(ctx, name).(string); ok {
+		return s
 	}
+	return fallback
+}
 
-	out := c.Output
-	if out == nil {
-		out = os.Stdout
+// flagBool is like flagString for boolean flags.
+func flagBool(ctx *kong.Context, name string, fallback bool) bool {
+	if b, ok := flagValue(ctx, name).(bool); ok {
+		return b
 	}
+	return fallback
+}
 
-	_, err = out.Write(src)
+// flagValue returns the parsed value of the selected command's flag with
+// the given name, or nil if there is no such flag.
+func flagValue(ctx *kong.Context, name string) any {
+	if ctx == nil || ctx.Selected() == nil {
+		return nil
+	}
+	for _, flag := range ctx.Selected().Flags {
+		if flag.Name == name {
+			return ctx.FlagValue(flag)
+		}
+	}
+	return nil
+}
+
+// renderConfigDoc renders config documentation in the given format,
+// optionally restricted to the section of one command.
+func renderConfigDoc(app *kong.Application, opts *schemaOptions, docFormat, command string) ([]byte, error) {
+	tree := buildConfigTree(app, opts)
+	section, err := tree.find(command)
+	if err != nil {
+		return nil, err
+	}
+
+	switch docFormat {
+	case "", "cue":
+		file := GenerateSchemaFile(app, opts)
+		if command != "" {
+			file = filterDefinitions(file, section)
+		}
+		src, err := format.Node(file)
+		if err != nil {
+			return nil, fmt.Errorf("failed to format schema: %w", err)
+		}
+		return src, nil
+	case "jsonschema":
+		src, err := json.MarshalIndent(jsonSchemaDocument(section), "", "  ")
+		if err != nil {
+			return nil, err
+		}
+		return append(src, '\n'), nil
+	case "yaml-example":
+		return renderSample(section, "yaml")
+	case "markdown":
+		var buf bytes.Buffer
+		if err := writeMarkdown(&buf, section, opts); err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	default:
+		return nil, fmt.Errorf("unknown format %q", docFormat)
+	}
+}
+
+// filterDefinitions keeps only the definitions for a section and its
+// descendants, including their Go-typed sections.
+func filterDefinitions(file *ast.File, section *configSection) *ast.File {
+	keep := make(map[string]bool)
+	section.walk(func(s *configSection) {
+		keep["#"+commandDefName(s.path)] = true
+		for _, key := range s.sections {
+			keep["#"+sectionDefName(configKey(s, key))] = true
+		}
+	})
+	if len(section.path) == 0 {
+		keep["#Root"] = true
+	}
+
+	filtered := &ast.File{}
+	for _, decl := range file.Decls {
+		field, ok := decl.(*ast.Field)
+		if !ok {
+			continue
+		}
+		if ident, ok := field.Label.(*ast.Ident); ok && keep[ident.Name] {
+			filtered.Decls = append(filtered.Decls, field)
+		}
+	}
+	return filtered
+}
+
+// ConfigInit is a Kong command that writes a commented starter config file
+// for the CLI. Embed this in your CLI struct so new users don't have to
+// write a config from the schema by hand.
+//
+// The sample contains every config key: flags with defaults are filled in,
+// the others are commented out, and help text is included as comments.
+// Existing files are not overwritten unless --force is given.
+//
+// Usage:
+//
+//	type cli struct {
+//	    Agent      agentCmd           `cmd:""`
+//	    ConfigInit kongcue.ConfigInit `cmd:"config-init" help:"Write a starter config file"`
+//	}
+//
+// Running `./myapp config-init --output config.yaml` writes the sample to
+// config.yaml; without --output it is printed to stdout.
+type ConfigInit struct {
+	File   string `name:"output" help:"Write to this file instead of stdout." type:"path" placeholder:"FILE"`
+	Format string `help:"File format: yaml, json or cue. Defaults to the output file's extension, or yaml." placeholder:"FORMAT"`
+	Force  bool   `help:"Overwrite the output file if it exists."`
+
+	// Output is the writer used when no file is given. Defaults to the
+	// Kong application's stdout. Exposed for testing; when set, the
+	// command returns instead of exiting.
+	Output io.Writer `kong:"-"`
+}
+
+// SkipsConfig reports that the config isn't loaded for config-init, which
+// may be run to replace a broken config.
+func (ConfigInit) SkipsConfig() bool {
+	return true
+}
+
+// BeforeApply writes the sample config. Like ConfigDoc, it runs before
+// validation so it works even when required flags are not set.
+func (c *ConfigInit) BeforeApply(app *kong.Kong, ctx *kong.Context, schemaOpts *SchemaOptions) error {
+	file := flagString(ctx, "output", c.File)
+	sampleFormat := flagString(ctx, "format", c.Format)
+	force := flagBool(ctx, "force", c.Force)
+
+	if sampleFormat == "" {
+		sampleFormat = sampleFormatFor(file)
+	}
+	src, err := GenerateSampleConfig(app.Model, schemaOpts, sampleFormat)
 	if err != nil {
 		return err
 	}
 
-	// Exit cleanly without running validation or the command.
-	// If Output is set (testing), don't exit so tests can check the output.
+	if file != "" {
+		if err := writeNewFile(file, src, force); err != nil {
+			return err
+		}
+	} else {
+		out := c.Output
+		if out == nil {
+			out = app.Stdout
+		}
+		if _, err := out.Write(src); err != nil {
+			return err
+		}
+	}
+
 	if c.Output == nil {
-		os.Exit(0)
+		app.Exit(0)
 	}
 	return nil
 }
+
+// sampleFormatFor picks the sample format from a file's extension,
+// defaulting to yaml.
+func sampleFormatFor(file string) string {
+	switch strings.ToLower(filepath.Ext(file)) {
+	case ".json":
+		return "json"
+	case ".cue":
+		return "cue"
+	default:
+		return "yaml"
+	}
+}
+
+// writeNewFile writes data to a file, refusing to replace an existing file
+// unless force is set.
+func writeNewFile(path string, data []byte, force bool) error {
+	flags := os.O_WRONLY | os.O_CREATE | os.O_TRUNC
+	if !force {
+		flags |= os.O_EXCL
+	}
+	f, err := os.OpenFile(path, flags, 0o644)
+	if errors.Is(err, fs.ErrExist) {
+		return fmt.Errorf("%s already exists, use --force to overwrite it", path)
+	}
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
@@ -2,9 +2,13 @@ package kongcue
 
 import (
 	"bytes"
+	"os"
+	"path/filepath"
+	"reflect"
 	"strings"
 	"testing"
 
+	"cuelang.org/go/cue/ast"
 	"github.com/alecthomas/kong"
 )
 
@@ -322,3 +326,244 @@ func TestCommandDefName(t *testing.T) {
 		})
 	}
 }
+
+func TestConfigDoc_Constraints(t *testing.T) {
+	var cli struct {
+		Port      int       `name:"port" cue:">=1 & <=65535"`
+		Mode      string    `name:"mode" cue:"\"fast\" | \"slow\""`
+		ConfigDoc ConfigDoc `cmd:"config-doc"`
+	}
+	var buf bytes.Buffer
+	cli.ConfigDoc.Output = &buf
+
+	parser, err := kong.New(&cli, Options())
+	if err != nil {
+		t.Fatalf("failed to create parser: %v", err)
+	}
+
+	_, err = parser.Parse([]string{"config-doc"})
+	if err != nil {
+		t.Fatalf("failed to parse: %v", err)
+	}
+
+	output := buf.String()
+	if !strings.Contains(output, "port?: int & (>=1 & <=65535)") {
+		t.Errorf("expected constrained port field, got:\n%s", output)
+	}
+	if !strings.Contains(output, `mode?: string & ("fast" | "slow")`) {
+		t.Errorf("expected grouped disjunction for mode, got:\n%s", output)
+	}
+}
+
+func TestKindToType(t *testing.T) {
+	tests := []struct {
+		kind     reflect.Kind
+		expected string
+	}{
+		{reflect.Int, "int"},
+		{reflect.Int8, "int8"},
+		{reflect.Int32, "int32"},
+		{reflect.Int64, "int64"},
+		{reflect.Uint, "uint"},
+		{reflect.Uint8, "uint8"},
+		{reflect.Uint16, "uint16"},
+		{reflect.Uint64, "uint64"},
+		{reflect.Float32, "float32"},
+		{reflect.Float64, "float64"},
+		{reflect.String, "string"},
+		{reflect.Bool, "bool"},
+		{reflect.Struct, "_"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.kind.String(), func(t *testing.T) {
+			ident, ok := kindToType(tt.kind).(*ast.Ident)
+			if !ok {
+				t.Fatalf("kindToType(%s) did not return an identifier", tt.kind)
+			}
+			if ident.Name != tt.expected {
+				t.Errorf("kindToType(%s) = %q, want %q", tt.kind, ident.Name, tt.expected)
+			}
+		})
+	}
+}
+
+func TestConfigDoc_MapFlags(t *testing.T) {
+	var cli struct {
+		Labels    map[string]string `name:"labels"`
+		Ports     map[int]string    `name:"ports"`
+		ConfigDoc ConfigDoc         `cmd:"config-doc"`
+	}
+	var buf bytes.Buffer
+	cli.ConfigDoc.Output = &buf
+
+	parser, err := kong.New(&cli, Options())
+	if err != nil {
+		t.Fatalf("failed to create parser: %v", err)
+	}
+
+	_, err = parser.Parse([]string{"config-doc"})
+	if err != nil {
+		t.Fatalf("failed to parse: %v", err)
+	}
+
+	output := buf.String()
+	if !strings.Contains(output, "labels?: {\n\t\t[string]: string\n\t}") {
+		t.Errorf("expected pattern constraint for labels, got:\n%s", output)
+	}
+	if !strings.Contains(output, `[=~"^-?[0-9]+$"]: string`) {
+		t.Errorf("expected numeric key pattern for ports, got:\n%s", output)
+	}
+}
+
+func parseConfigDoc(t *testing.T, args ...string) string {
+	t.Helper()
+	var cli nestedCLI
+	var buf bytes.Buffer
+	cli.ConfigDoc.Output = &buf
+
+	parser, err := kong.New(&cli, Options())
+	if err != nil {
+		t.Fatalf("failed to create parser: %v", err)
+	}
+	if _, err := parser.Parse(append([]string{"config-doc"}, args...)); err != nil {
+		t.Fatalf("failed to parse: %v", err)
+	}
+	return buf.String()
+}
+
+func TestConfigDoc_CommandSubtree(t *testing.T) {
+	output := parseConfigDoc(t, "--command", "server.tls")
+
+	if !strings.Contains(output, "#ServerTls:") {
+		t.Errorf("expected #ServerTls definition, got:\n%s", output)
+	}
+	if strings.Contains(output, "#Root:") || strings.Contains(output, "#Server:") {
+		t.Errorf("expected only the server.tls subtree, got:\n%s", output)
+	}
+}
+
+func TestConfigDoc_UnknownCommand(t *testing.T) {
+	var cli nestedCLI
+	cli.ConfigDoc.Output = &bytes.Buffer{}
+
+	parser, err := kong.New(&cli, Options())
+	if err != nil {
+		t.Fatalf("failed to create parser: %v", err)
+	}
+	_, err = parser.Parse([]string{"config-doc", "--command", "client"})
+	if err == nil || !strings.Contains(err.Error(), `unknown command "client"`) {
+		t.Errorf("expected unknown command error, got: %v", err)
+	}
+}
+
+func TestConfigDoc_JSONSchemaFormat(t *testing.T) {
+	output := parseConfigDoc(t, "--format", "jsonschema")
+
+	if !strings.Contains(output, `"additionalProperties": false`) {
+		t.Errorf("expected closed objects, got:\n%s", output)
+	}
+	if !strings.Contains(output, `"cert_file": {`) || !strings.Contains(output, `"type": "string"`) {
+		t.Errorf("expected cert_file string property, got:\n%s", output)
+	}
+}
+
+func TestConfigDoc_YAMLExampleFormat(t *testing.T) {
+	var cli struct {
+		Name   string `help:"Who to greet" default:"world"`
+		Server struct {
+			Port int `help:"Listen port" default:"8080"`
+			Host string
+		} `cmd:"" help:"Run the server"`
+		ConfigDoc ConfigDoc `cmd:"config-doc"`
+	}
+	var buf bytes.Buffer
+	cli.ConfigDoc.Output = &buf
+
+	parser, err := kong.New(&cli, Options())
+	if err != nil {
+		t.Fatalf("failed to create parser: %v", err)
+	}
+	if _, err := parser.Parse([]string{"config-doc", "--format", "yaml-example"}); err != nil {
+		t.Fatalf("failed to parse: %v", err)
+	}
+
+	expected := `# Who to greet
+name: "world"
+
+# Run the server
+server:
+  # Listen port
+  port: 8080
+
+  # host: ""
+`
+	if buf.String() != expected {
+		t.Errorf("unexpected YAML example:\n%s\nwant:\n%s", buf.String(), expected)
+	}
+}
+
+func TestConfigDoc_MarkdownFormat(t *testing.T) {
+	output := parseConfigDoc(t, "--format", "markdown")
+
+	if !strings.Contains(output, "## `server.tls`") {
+		t.Errorf("expected server.tls section, got:\n%s", output)
+	}
+	if !strings.Contains(output, "| `server.tls.cert_file` | `string` |") {
+		t.Errorf("expected cert_file row, got:\n%s", output)
+	}
+}
+
+func TestConfigDoc_OutputFile(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "schema.cue")
+	var cli nestedCLI
+	var stdout bytes.Buffer
+	exited := -1
+
+	parser, err := kong.New(&cli, Options(),
+		kong.Writers(&stdout, &stdout),
+		kong.Exit(func(code int) { exited = code }))
+	if err != nil {
+		t.Fatalf("failed to create parser: %v", err)
+	}
+	if _, err := parser.Parse([]string{"config-doc", "--output", file}); err != nil {
+		t.Fatalf("failed to parse: %v", err)
+	}
+
+	if exited != 0 {
+		t.Errorf("expected app.Exit(0), got %d", exited)
+	}
+	if stdout.Len() != 0 {
+		t.Errorf("expected nothing on stdout, got:\n%s", stdout.String())
+	}
+	data, err := os.ReadFile(file)
+	if err != nil {
+		t.Fatalf("failed to read output file: %v", err)
+	}
+	if !strings.Contains(string(data), "#Root:") {
+		t.Errorf("expected schema in output file, got:\n%s", data)
+	}
+}
+
+func TestConfigDoc_ExitIsInterceptable(t *testing.T) {
+	var cli nestedCLI
+	var stdout bytes.Buffer
+	exited := -1
+
+	parser, err := kong.New(&cli, Options(),
+		kong.Writers(&stdout, &stdout),
+		kong.Exit(func(code int) { exited = code }))
+	if err != nil {
+		t.Fatalf("failed to create parser: %v", err)
+	}
+	if _, err := parser.Parse([]string{"config-doc"}); err != nil {
+		t.Fatalf("failed to parse: %v", err)
+	}
+
+	if exited != 0 {
+		t.Errorf("expected app.Exit(0), got %d", exited)
+	}
+	if !strings.Contains(stdout.String(), "#Root:") {
+		t.Errorf("expected schema on the application's stdout, got:\n%s", stdout.String())
+	}
+}
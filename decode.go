@@ -0,0 +1,89 @@
+package kongcue
+
+import (
+	"fmt"
+	"reflect"
+
+	"cuelang.org/go/cue"
+	"github.com/alecthomas/kong"
+)
+
+// Decode decodes the config at a dot-separated path, such as "messy" or
+// "agent.extra", into target, which must be a pointer. It's meant for
+// sections allowed with AllowUnknownFields, which have no schema of their
+// own:
+//
+//	func (a *AgentCmd) Run(ctx *kong.Context) error {
+//	    var messy MessyConfig
+//	    if err := kongcue.Decode(ctx, "messy", &messy); err != nil {
+//	        return err
+//	    }
+//	    ...
+//	}
+//
+// Fields are matched using json struct tags. The section is first checked
+// against a CUE schema generated from target's type, so type errors and
+// constraints from cue:"..." struct tags are reported as a ValidationError
+// positioned in the config file. Keys without a matching field are
+// ignored. Targets of interface types, like *any, are decoded without a
+// check. If the path isn't set, target is left unchanged.
+func Decode(ctx *kong.Context, path string, target any) error {
+	loaded, err := loadedFrom(ctx)
+	if err != nil {
+		return err
+	}
+	return loaded.Decode(path, target)
+}
+
+// Lookup is like Decode, but returns the decoded value. It returns the
+// zero value of T if the path isn't set.
+//
+//	plugins, err := kongcue.Lookup[[]Plugin](ctx, "plugins")
+func Lookup[T any](ctx *kong.Context, path string) (T, error) {
+	var out T
+	err := Decode(ctx, path, &out)
+	return out, err
+}
+
+// Decode decodes the config at a dot-separated path into target; see the
+// Decode function.
+func (l *Loaded) Decode(path string, target any) error {
+	rv := reflect.ValueOf(target)
+	if rv.Kind() != reflect.Pointer || rv.IsNil() {
+		return fmt.Errorf("cannot decode config into non-pointer %T", target)
+	}
+	cuePath := cue.ParsePath(path)
+	if err := cuePath.Err(); err != nil {
+		return fmt.Errorf("invalid config path %q: %w", path, err)
+	}
+	val := l.Value.LookupPath(cuePath)
+	if !val.Exists() {
+		return nil
+	}
+
+	// Interface types, like any, take whatever is there
+	if rv.Elem().Kind() != reflect.Interface {
+		schema := val.Context().EncodeType(rv.Elem().Interface())
+		if err := schema.Err(); err != nil {
+			return fmt.Errorf("cannot generate a schema for %T: %w", target, err)
+		}
+		// Missing fields are left as they are, so only check the keys set
+		checked := l.Value.FillPath(cuePath, schema).LookupPath(cuePath)
+		if err := checked.Validate(); err != nil {
+			return newValidationError(nil, nil, err)
+		}
+	}
+	if err := val.Decode(target); err != nil {
+		return fmt.Errorf("failed to decode %s: %w", path, err)
+	}
+	return nil
+}
+
+// loadedFrom returns the config bound by Config in a Kong context.
+func loadedFrom(ctx *kong.Context) (*Loaded, error) {
+	var loaded *Loaded
+	if _, err := ctx.Call(func(l *Loaded) { loaded = l }); err != nil {
+		return nil, fmt.Errorf("no config loaded (is kongcue.Config part of the CLI?): %w", err)
+	}
+	return loaded, nil
+}
@@ -0,0 +1,105 @@
+package kongcue_test
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/alecthomas/kong"
+	kongcue "github.com/brianm/kongcue"
+)
+
+type messyConfig struct {
+	Name    string            `json:"name"`
+	Timeout int               `json:"timeout,omitempty" cue:">0"`
+	Labels  map[string]string `json:"labels,omitempty"`
+	Hosts   []string          `json:"hosts,omitempty"`
+}
+
+type decodeCLI struct {
+	Config kongcue.Config `default:"./config.yaml"`
+	Agent  struct {
+		Port int `name:"port"`
+	} `cmd:""`
+}
+
+func parseDecode(t *testing.T, config string) *kong.Context {
+	t.Helper()
+	ctx, err := kongcue.ParseWithConfig(t, &decodeCLI{}, config, []kong.Option{kongcue.AllowUnknownFields("messy")}, "agent")
+	if err != nil {
+		t.Fatalf("failed to parse: %v", err)
+	}
+	return ctx
+}
+
+func TestDecode(t *testing.T) {
+	ctx := parseDecode(t, "messy:\n  name: x\n  timeout: 5\n  labels: {a: b}\n  hosts: [h1, h2]\n  other: true\n")
+
+	var got messyConfig
+	if err := kongcue.Decode(ctx, "messy", &got); err != nil {
+		t.Fatalf("failed to decode: %v", err)
+	}
+	if got.Name != "x" || got.Timeout != 5 || got.Labels["a"] != "b" || len(got.Hosts) != 2 {
+		t.Errorf("unexpected value %+v", got)
+	}
+}
+
+func TestDecode_Missing(t *testing.T) {
+	ctx := parseDecode(t, "agent:\n  port: 1\n")
+
+	got := messyConfig{Name: "default"}
+	if err := kongcue.Decode(ctx, "messy", &got); err != nil || got.Name != "default" {
+		t.Errorf("expected target to be left unchanged, got %+v, %v", got, err)
+	}
+}
+
+func TestDecode_Validates(t *testing.T) {
+	tests := []struct {
+		config, want string
+	}{
+		{"messy:\n  name: 3\n", "messy.name: conflicting values 3 and string"},
+		{"messy:\n  timeout: 0\n", "messy.timeout: invalid value 0 (out of bound >0)"},
+	}
+	for _, tt := range tests {
+		ctx := parseDecode(t, tt.config)
+		var got messyConfig
+		err := kongcue.Decode(ctx, "messy", &got)
+		var verr *kongcue.ValidationError
+		if !errors.As(err, &verr) || !strings.Contains(err.Error(), tt.want) || !strings.Contains(err.Error(), "config.yaml:") {
+			t.Errorf("config %q: expected a positioned ValidationError %q, got %v", tt.config, tt.want, err)
+		}
+	}
+}
+
+func TestLookup(t *testing.T) {
+	ctx := parseDecode(t, "messy:\n  hosts: [h1, h2]\n")
+
+	hosts, err := kongcue.Lookup[[]string](ctx, "messy.hosts")
+	if err != nil || len(hosts) != 2 || hosts[1] != "h2" {
+		t.Errorf("unexpected hosts %v, %v", hosts, err)
+	}
+	port, err := kongcue.Lookup[int](ctx, "agent.port")
+	if err != nil || port != 0 {
+		t.Errorf("expected zero for an unset key, got %v, %v", port, err)
+	}
+}
+
+func TestLookup_Interface(t *testing.T) {
+	ctx := parseDecode(t, "messy:\n  name: x\n  hosts: [h1]\n")
+
+	messy, err := kongcue.Lookup[any](ctx, "messy")
+	if err != nil {
+		t.Fatalf("failed to decode: %v", err)
+	}
+	m, ok := messy.(map[string]any)
+	if !ok || m["name"] != "x" {
+		t.Errorf("unexpected value %#v", messy)
+	}
+}
+
+func TestDecode_NotPointer(t *testing.T) {
+	ctx := parseDecode(t, "messy: {}\n")
+	if err := kongcue.Decode(ctx, "messy", messyConfig{}); err == nil {
+		t.Error("expected an error for a non-pointer target")
+	}
+}
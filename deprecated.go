@@ -0,0 +1,133 @@
+package kongcue
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"cuelang.org/go/cue"
+	"cuelang.org/go/cue/ast"
+	"cuelang.org/go/cue/token"
+	"github.com/alecthomas/kong"
+)
+
+// deprecatedTag marks flags whose config key is deprecated, with a message
+// for users, e.g. `deprecated:"use ca_url"`. Deprecated keys are still
+// accepted, even when the flag is hidden, but each use is reported as a
+// warning.
+const deprecatedTag = "deprecated"
+
+// Warning is a config problem that doesn't stop the config from being
+// used, such as a deprecated key (of kind IssueDeprecatedKey). It is
+// positioned in the config file that caused it.
+type Warning Issue
+
+// String formats the warning as "file:line:column: path: message".
+func (w Warning) String() string {
+	return Issue(w).String()
+}
+
+// aliasFields returns optional schema fields for the old names of a flag
+// taken from its aliases, so configs written before a rename still load.
+func aliasFields(flag *kong.Flag, opts *schemaOptions) []ast.Decl {
+	var fields []ast.Decl
+	for _, alias := range flag.Aliases {
+		field := &ast.Field{
+			Label:      ast.NewIdent(kebabToSnake(alias)),
+			Constraint: token.OPTION,
+			Value:      flagType(flag, opts),
+		}
+		addDocComment(field, "Deprecated: renamed to "+kebabToSnake(flag.Name))
+		fields = append(fields, field)
+	}
+	return fields
+}
+
+// flagDocComment returns the schema comment for a flag: its help text,
+// followed by its deprecation message if it has one.
+func flagDocComment(flag *kong.Flag) string {
+	if !flag.Tag.Has(deprecatedTag) {
+		return flag.Help
+	}
+	note := "Deprecated"
+	if msg := flag.Tag.Get(deprecatedTag); msg != "" {
+		note += ": " + msg
+	}
+	if flag.Help == "" {
+		return note
+	}
+	return flag.Help + " (" + note + ")"
+}
+
+// deprecationWarnings reports each deprecated key set in a config: keys of
+// flags tagged deprecated, and old key names taken from flag aliases.
+func deprecationWarnings(app *kong.Application, config cue.Value) []Warning {
+	var warnings []Warning
+	buildConfigTree(app, nil).walk(func(section *configSection) {
+		for _, flag := range section.flags {
+			key := append(append([]string{}, section.path...), kebabToSnake(flag.Name))
+			if flag.Tag.Has(deprecatedTag) {
+				if val := config.LookupPath(cue.ParsePath(strings.Join(key, "."))); val.Exists() {
+					message := "deprecated"
+					if msg := flag.Tag.Get(deprecatedTag); msg != "" {
+						message += ": " + msg
+					}
+					warnings = append(warnings, newWarning(val, key, section, flag, message))
+				}
+			}
+			for _, alias := range flag.Aliases {
+				old := append(append([]string{}, section.path...), kebabToSnake(alias))
+				if val := config.LookupPath(cue.ParsePath(strings.Join(old, "."))); val.Exists() {
+					warnings = append(warnings, newWarning(val, old, section, flag, "deprecated, renamed to "+strings.Join(key, ".")))
+				}
+			}
+		}
+	})
+	return warnings
+}
+
+// newWarning creates a deprecated key warning for a flag of a section,
+// positioned at a config value.
+func newWarning(val cue.Value, path []string, section *configSection, flag *kong.Flag, message string) Warning {
+	w := Warning{
+		Kind:    IssueDeprecatedKey,
+		Path:    strings.Join(path, "."),
+		Flag:    "--" + flag.Name,
+		Env:     flag.Envs,
+		Command: section.command(),
+		Message: message,
+	}
+	if pos := configPos(val); pos.IsValid() {
+		w.File, w.Line, w.Column = pos.Filename(), pos.Line(), pos.Column()
+	}
+	return w
+}
+
+// handleWarnings passes warnings to opts.OnWarning, or prints them to w if
+// it isn't set. With opts.StrictWarnings, warnings are returned as an error
+// instead.
+func handleWarnings(warnings []Warning, opts *SchemaOptions, w io.Writer) error {
+	if len(warnings) == 0 {
+		return nil
+	}
+	if opts != nil && opts.StrictWarnings {
+		return warningsError(warnings)
+	}
+	for _, warning := range warnings {
+		if opts != nil && opts.OnWarning != nil {
+			opts.OnWarning(warning)
+		} else {
+			fmt.Fprintf(w, "warning: %s\n", warning)
+		}
+	}
+	return nil
+}
+
+// warningsError returns warnings as an error, for StrictWarnings.
+func warningsError(warnings []Warning) error {
+	lines := make([]string, len(warnings))
+	for i, warning := range warnings {
+		lines[i] = warning.String()
+	}
+	return fmt.Errorf("config has %d warning(s):\n%s", len(warnings), strings.Join(lines, "\n"))
+}
@@ -0,0 +1,226 @@
+package kongcue_test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/alecthomas/kong"
+	kongcue "github.com/brianm/kongcue"
+)
+
+type deprecatedCLI struct {
+	Config  kongcue.Config `default:"./config.yaml"`
+	Verbose bool           `name:"verbose" hidden:"" deprecated:"use log_level"`
+	Level   string         `name:"log-level" default:"info"`
+	Agent   struct {
+		CaURL string `name:"ca-url" aliases:"ca,authority" required:""`
+	} `cmd:""`
+	ConfigValidate kongcue.ConfigValidate `cmd:"config-validate"`
+}
+
+// parseDeprecated parses args with a config file and returns the CLI and
+// the warnings reported.
+func parseDeprecated(t *testing.T, config string, opts *kongcue.SchemaOptions, args ...string) (*deprecatedCLI, []kongcue.Warning, error) {
+	t.Helper()
+	var warnings []kongcue.Warning
+	if opts == nil {
+		opts = &kongcue.SchemaOptions{}
+	}
+	if opts.OnWarning == nil && !opts.StrictWarnings {
+		opts.OnWarning = func(w kongcue.Warning) { warnings = append(warnings, w) }
+	}
+
+	var cli deprecatedCLI
+	_, err := kongcue.ParseWithConfig(t, &cli, config, []kong.Option{kong.Bind(opts)}, args...)
+	return &cli, warnings, err
+}
+
+func TestDeprecated_AliasKeys(t *testing.T) {
+	cli, warnings, err := parseDeprecated(t, "agent:\n  ca: https://old.example.com\n", nil, "agent")
+	if err != nil {
+		t.Fatalf("old key name should be accepted: %v", err)
+	}
+	if cli.Agent.CaURL != "https://old.example.com" {
+		t.Errorf("expected old key to set --ca-url, got %q", cli.Agent.CaURL)
+	}
+	if len(warnings) != 1 {
+		t.Fatalf("expected one warning, got %v", warnings)
+	}
+	if got := warnings[0].String(); !strings.HasSuffix(got, "/config.yaml:2:3: agent.ca: deprecated, renamed to agent.ca_url (flag agent --ca-url)") {
+		t.Errorf("unexpected warning %q", got)
+	}
+}
+
+func TestDeprecated_NewKeyWins(t *testing.T) {
+	cli, warnings, err := parseDeprecated(t, "agent:\n  ca_url: https://new\n  authority: https://old\n", nil, "agent")
+	if err != nil {
+		t.Fatalf("failed to parse: %v", err)
+	}
+	if cli.Agent.CaURL != "https://new" {
+		t.Errorf("expected the new key to take precedence, got %q", cli.Agent.CaURL)
+	}
+	if len(warnings) != 1 || warnings[0].Path != "agent.authority" {
+		t.Errorf("expected a warning for agent.authority, got %v", warnings)
+	}
+}
+
+func TestDeprecated_TaggedFlag(t *testing.T) {
+	cli, warnings, err := parseDeprecated(t, "verbose: true\nagent:\n  ca_url: x\n", nil, "agent")
+	if err != nil {
+		t.Fatalf("hidden deprecated flag should be accepted: %v", err)
+	}
+	if !cli.Verbose {
+		t.Error("expected verbose to be set from config")
+	}
+	if len(warnings) != 1 || !strings.HasSuffix(warnings[0].String(), "/config.yaml:1:1: verbose: deprecated: use log_level (flag --verbose)") {
+		t.Errorf("unexpected warnings %v", warnings)
+	}
+}
+
+func TestDeprecated_StrictWarnings(t *testing.T) {
+	opts := &kongcue.SchemaOptions{StrictWarnings: true}
+	_, _, err := parseDeprecated(t, "agent:\n  ca: x\n", opts, "agent")
+	if err == nil || !strings.Contains(err.Error(), "agent.ca: deprecated, renamed to agent.ca_url") {
+		t.Errorf("expected warnings to be errors, got %v", err)
+	}
+}
+
+func TestDeprecated_OptionHelpers(t *testing.T) {
+	dir := t.TempDir()
+	t.Chdir(dir)
+	kongcue.WriteConfig(t, dir, "config.yaml", "agent:\n  ca: x\n")
+
+	parse := func(options ...kong.Option) error {
+		var cli deprecatedCLI
+		parser, err := kong.New(&cli, options...)
+		if err != nil {
+			t.Fatalf("failed to create parser: %v", err)
+		}
+		_, err = parser.Parse([]string{"agent"})
+		return err
+	}
+
+	var warnings []kongcue.Warning
+	err := parse(kongcue.Options(), kongcue.OnWarning(func(w kongcue.Warning) { warnings = append(warnings, w) }), kongcue.AllowUnknownFields())
+	if err != nil || len(warnings) != 1 {
+		t.Errorf("expected one warning passed to OnWarning, got %v (err %v)", warnings, err)
+	}
+
+	err = parse(kongcue.StrictWarnings(), kongcue.AllowUnknownFields())
+	if err == nil || !strings.Contains(err.Error(), "agent.ca: deprecated") {
+		t.Errorf("expected warnings to be errors, got %v", err)
+	}
+}
+
+func TestDeprecated_DefaultPrintsToStderr(t *testing.T) {
+	dir := t.TempDir()
+	t.Chdir(dir)
+	kongcue.WriteConfig(t, dir, "config.yaml", "agent:\n  ca: x\n")
+
+	var cli deprecatedCLI
+	var stderr bytes.Buffer
+	parser, err := kong.New(&cli, kongcue.Options(), kong.Writers(&bytes.Buffer{}, &stderr))
+	if err != nil {
+		t.Fatalf("failed to create parser: %v", err)
+	}
+	if _, err := parser.Parse([]string{"agent"}); err != nil {
+		t.Fatalf("failed to parse: %v", err)
+	}
+	if got := stderr.String(); !strings.HasPrefix(got, "warning: ") || !strings.Contains(got, "/config.yaml:2:3: agent.ca: deprecated") {
+		t.Errorf("expected warning on stderr, got %q", stderr.String())
+	}
+}
+
+func TestDeprecated_Schema(t *testing.T) {
+	var cli deprecatedCLI
+	parser, err := kong.New(&cli, kongcue.Options())
+	if err != nil {
+		t.Fatalf("failed to create parser: %v", err)
+	}
+
+	src, err := kongcue.GenerateJSONSchema(parser.Model, nil)
+	if err != nil {
+		t.Fatalf("failed to generate schema: %v", err)
+	}
+	for _, key := range []string{`"ca"`, `"authority"`, `"verbose"`} {
+		if !strings.Contains(string(src), key) {
+			t.Errorf("expected %s in schema:\n%s", key, src)
+		}
+	}
+
+	sample, err := kongcue.GenerateSampleConfig(parser.Model, nil, "yaml")
+	if err != nil {
+		t.Fatalf("failed to generate sample: %v", err)
+	}
+	if strings.Contains(string(sample), "verbose") || strings.Contains(string(sample), "authority") {
+		t.Errorf("sample should not suggest deprecated keys:\n%s", sample)
+	}
+}
+
+func TestConfigValidate_Warnings(t *testing.T) {
+	dir := t.TempDir()
+	path := kongcue.WriteConfig(t, dir, "config.yaml", "verbose: true\n")
+
+	run := func(opts *kongcue.SchemaOptions) (string, error) {
+		var cli deprecatedCLI
+		var out bytes.Buffer
+		cli.ConfigValidate.Output = &out
+		parser, err := kong.New(&cli, kong.Bind(opts))
+		if err != nil {
+			t.Fatalf("failed to create parser: %v", err)
+		}
+		_, err = parser.Parse([]string{"config-validate", path})
+		return out.String(), err
+	}
+
+	out, err := run(&kongcue.SchemaOptions{})
+	if err != nil || !strings.Contains(out, "warning: "+path+":1:1: verbose: deprecated: use log_level") {
+		t.Errorf("expected a warning and success, got %v:\n%s", err, out)
+	}
+
+	out, err = run(&kongcue.SchemaOptions{StrictWarnings: true})
+	if err == nil || !strings.Contains(out, path+":1:1: verbose: deprecated") {
+		t.Errorf("expected strict validation to fail, got %v:\n%s", err, out)
+	}
+}
+
+func TestDeprecated_SuggestsCurrentNames(t *testing.T) {
+	tests := []struct {
+		config, want string
+	}{
+		// Old names of a renamed flag suggest the new name
+		{"agent:\n  cb: x\n", "agent.cb: unknown key; did you mean ca_url?"},
+		{"agent:\n  ca_url: x\n  authorty: x\n", "agent.authorty: unknown key; did you mean ca_url?"},
+		// Deprecated keys aren't suggested
+		{"verbos: true\nagent:\n  ca_url: x\n", "verbos: unknown key\n"},
+	}
+	for _, tt := range tests {
+		_, _, err := parseDeprecated(t, tt.config, nil, "agent")
+		if err == nil || !strings.Contains(err.Error()+"\n", tt.want) {
+			t.Errorf("config %q: expected %q, got %v", tt.config, tt.want, err)
+		}
+	}
+}
+
+func TestValidate_Warnings(t *testing.T) {
+	path := kongcue.WriteConfig(t, t.TempDir(), "config.yaml", "agent:\n  ca: https://old.example.com\n")
+	var cli deprecatedCLI
+	parser, err := kong.New(&cli)
+	if err != nil {
+		t.Fatalf("failed to create parser: %v", err)
+	}
+
+	warnings, err := kongcue.Validate(parser.Model, []string{path}, nil)
+	if err != nil {
+		t.Fatalf("deprecated keys should be accepted: %v", err)
+	}
+	if len(warnings) != 1 || !strings.Contains(warnings[0].String(), "agent.ca: deprecated, renamed to agent.ca_url") {
+		t.Errorf("expected the warning to be returned, got %v", warnings)
+	}
+
+	_, err = kongcue.Validate(parser.Model, []string{path}, &kongcue.SchemaOptions{StrictWarnings: true})
+	if err == nil || !strings.Contains(err.Error(), "agent.ca: deprecated") {
+		t.Errorf("expected warnings to be an error with StrictWarnings, got %v", err)
+	}
+}
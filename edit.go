@@ -0,0 +1,579 @@
+package kongcue
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"slices"
+	"strings"
+
+	"cuelang.org/go/cue/ast"
+	"cuelang.org/go/cue/cuecontext"
+	"cuelang.org/go/cue/format"
+	"cuelang.org/go/cue/parser"
+	"github.com/alecthomas/kong"
+	"go.yaml.in/yaml/v3"
+)
+
+// configEdit is a change to one key of a config file.
+type configEdit struct {
+	path   []string // config keys from the root, e.g. ["agent", "ca_url"]
+	value  any      // native config value to set
+	remove bool     // remove the key instead of setting it
+}
+
+// SetConfigValue sets a key in a config file, like `git config`. The key
+// is a dotted config path (e.g. "agent.ca_url") and the value is given in
+// command line syntax, so "a,b" sets a list for a slice flag. Both are
+// checked against the schema generated from app (types, enums and cue:""
+// constraints) before the file is written; opts may be nil.
+//
+// The file is created if needed. YAML and CUE files are updated in place,
+// keeping comments and the order of keys.
+func SetConfigValue(app *kong.Application, path, key, value string, opts *SchemaOptions) error {
+	internal := opts.toInternal()
+	keys := strings.Split(key, ".")
+	flag, err := lookupConfigKey(buildConfigTree(app, internal), keys)
+	if err != nil {
+		return err
+	}
+
+	var native any = value
+	if flag != nil {
+		native = cliToConfigValue(flag.Value, value, internal)
+		if err := checkEnum(flag, native); err != nil {
+			return fmt.Errorf("%s: %w", key, err)
+		}
+	}
+
+	cctx := cuecontext.New()
+	schema, err := GenerateSchema(cctx, app, internal)
+	if err != nil {
+		return fmt.Errorf("failed to generate config schema: %w", err)
+	}
+	val := cctx.Encode(nestValue(keys, native))
+	if err := val.Unify(schema).Validate(); err != nil {
+		return newValidationError(app, internal, err)
+	}
+
+	return editConfigFile(path, []configEdit{{path: keys, value: native}}, internal)
+}
+
+// UnsetConfigValue removes a key from a config file. Sections left empty
+// are removed as well. It is an error if the key is not set in the file.
+func UnsetConfigValue(path, key string) error {
+	return editConfigFile(path, []configEdit{{path: strings.Split(key, "."), remove: true}}, nil)
+}
+
+// lookupConfigKey returns the flag a dotted config key sets. Keys under
+// sections that allow unknown fields return a nil flag.
+func lookupConfigKey(section *configSection, keys []string) (*kong.Flag, error) {
+	name := strings.Join(keys, ".")
+	for i, key := range keys {
+		last := i == len(keys)-1
+		if last {
+			for _, flag := range section.flags {
+				if kebabToSnake(flag.Name) == key {
+					return flag, nil
+				}
+			}
+		}
+		if slices.Contains(section.sections, key) || slices.Contains(section.extra, key) {
+			return nil, nil
+		}
+		var next *configSection
+		for _, child := range section.children {
+			if child.path[len(child.path)-1] == key {
+				next = child
+				break
+			}
+		}
+		switch {
+		case next != nil && !last:
+			section = next
+		case next != nil:
+			return nil, fmt.Errorf("%s is a section, not a key", name)
+		case section.open:
+			return nil, nil
+		default:
+			if hint := didYouMean(suggestKeys(key, section.keys())); hint != "" {
+				return nil, fmt.Errorf("unknown config key %q; %s", name, hint)
+			}
+			return nil, fmt.Errorf("unknown config key %q", name)
+		}
+	}
+	return nil, fmt.Errorf("unknown config key %q", name)
+}
+
+// checkEnum checks a value (or each element of a list) against the flag's
+// enum values.
+func checkEnum(flag *kong.Flag, value any) error {
+	if flag.Enum == "" {
+		return nil
+	}
+	values := []any{value}
+	if items, ok := value.([]any); ok {
+		values = items
+	}
+	allowed := flag.EnumSlice()
+	for _, v := range values {
+		if !slices.Contains(allowed, fmt.Sprint(v)) {
+			return fmt.Errorf("invalid value %q, must be one of %s", fmt.Sprint(v), strings.Join(allowed, ", "))
+		}
+	}
+	return nil
+}
+
+// nestValue wraps a value in maps for each key, e.g. a.b: value.
+func nestValue(keys []string, value any) any {
+	for i := len(keys) - 1; i >= 0; i-- {
+		value = map[string]any{keys[i]: value}
+	}
+	return value
+}
+
+// editConfigFile applies edits to a config file, creating it if needed.
+// The format follows the file's extension: .cue, .json, or YAML otherwise.
+// New files of versioned configs start with the latest config_version.
+func editConfigFile(path string, edits []configEdit, opts *schemaOptions) error {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		if latest := opts.latestConfigVersion(); latest > 0 {
+			edits = append([]configEdit{{path: []string{versionKey}, value: latest}}, edits...)
+		}
+	} else if err != nil {
+		return err
+	}
+
+	edited, err := editConfig(path, data, edits)
+	if err != nil {
+		return fmt.Errorf("failed to update %s: %w", path, err)
+	}
+	return os.WriteFile(path, edited, 0o644)
+}
+
+// editConfig applies edits to the content of a config file in the format
+// of its extension.
+func editConfig(path string, data []byte, edits []configEdit) ([]byte, error) {
+	switch sampleFormatFor(path) {
+	case "cue":
+		return editCUE(path, data, edits)
+	case "json":
+		return editJSON(data, edits)
+	default:
+		return editYAML(data, edits)
+	}
+}
+
+// errNotSet is returned when removing a key that isn't in the file.
+func errNotSet(path []string) error {
+	return fmt.Errorf("%s is not set", strings.Join(path, "."))
+}
+
+// editYAML applies edits to a YAML document, keeping comments and the
+// order of existing keys.
+func editYAML(data []byte, edits []configEdit) ([]byte, error) {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+	var prefix []byte
+	if doc.Kind == 0 {
+		// The file has nothing but comments, which the parser drops, so
+		// keep them above the new keys
+		if len(bytes.TrimSpace(data)) > 0 {
+			prefix = append(bytes.TrimRight(data, "\n"), '\n')
+		}
+		doc = yaml.Node{Kind: yaml.DocumentNode, Content: []*yaml.Node{{Kind: yaml.MappingNode, Tag: "!!map"}}}
+	}
+	root := doc.Content[0]
+	if root.Kind == yaml.ScalarNode && root.Tag == "!!null" {
+		// An empty document like "---" becomes a mapping, keeping its comments
+		*root = yaml.Node{Kind: yaml.MappingNode, Tag: "!!map", HeadComment: root.HeadComment, LineComment: root.LineComment, FootComment: root.FootComment}
+	}
+	if root.Kind != yaml.MappingNode {
+		return nil, fmt.Errorf("expected a mapping at the top level")
+	}
+
+	for _, e := range edits {
+		if e.remove {
+			if !removeYAML(root, e.path) {
+				return nil, errNotSet(e.path)
+			}
+			continue
+		}
+		if err := setYAML(root, e.path, e.value); err != nil {
+			return nil, err
+		}
+	}
+
+	buf := bytes.NewBuffer(prefix)
+	enc := yaml.NewEncoder(buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(&doc); err != nil {
+		return nil, err
+	}
+	if err := enc.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// yamlKey returns the index of a key in a YAML mapping node's content, or
+// -1 if it isn't there.
+func yamlKey(mapping *yaml.Node, key string) int {
+	for i := 0; i+1 < len(mapping.Content); i += 2 {
+		if mapping.Content[i].Value == key {
+			return i
+		}
+	}
+	return -1
+}
+
+// setYAML sets a nested key in a YAML mapping node, creating intermediate
+// mappings as needed. Replaced values keep their comments.
+func setYAML(mapping *yaml.Node, path []string, value any) error {
+	key := path[0]
+	var existing *yaml.Node
+	if i := yamlKey(mapping, key); i >= 0 {
+		existing = mapping.Content[i+1]
+	}
+
+	if len(path) > 1 {
+		if existing == nil {
+			existing = &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+			mapping.Content = append(mapping.Content, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: key}, existing)
+		}
+		if existing.Kind != yaml.MappingNode {
+			return fmt.Errorf("%s: expected a mapping", key)
+		}
+		return setYAML(existing, path[1:], value)
+	}
+
+	var node yaml.Node
+	if err := node.Encode(value); err != nil {
+		return err
+	}
+	if existing == nil {
+		mapping.Content = append(mapping.Content, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: key}, &node)
+		return nil
+	}
+	node.HeadComment, node.LineComment, node.FootComment = existing.HeadComment, existing.LineComment, existing.FootComment
+	*existing = node
+	return nil
+}
+
+// removeYAML removes a nested key from a YAML mapping node, along with
+// mappings left empty. Returns false if the key isn't there.
+func removeYAML(mapping *yaml.Node, path []string) bool {
+	i := yamlKey(mapping, path[0])
+	if i < 0 {
+		return false
+	}
+	if len(path) > 1 {
+		child := mapping.Content[i+1]
+		if child.Kind != yaml.MappingNode || !removeYAML(child, path[1:]) {
+			return false
+		}
+		if len(child.Content) > 0 {
+			return true
+		}
+	}
+	mapping.Content = slices.Delete(mapping.Content, i, i+2)
+	return true
+}
+
+// editCUE applies edits to a CUE file, keeping comments and the order of
+// existing fields.
+func editCUE(path string, data []byte, edits []configEdit) ([]byte, error) {
+	file, err := parser.ParseFile(path, data, parser.ParseComments)
+	if err != nil {
+		return nil, err
+	}
+	cctx := cuecontext.New()
+	for _, e := range edits {
+		if e.remove {
+			if !removeCUE(&file.Decls, e.path) {
+				return nil, errNotSet(e.path)
+			}
+			continue
+		}
+		expr, ok := cctx.Encode(e.value).Syntax().(ast.Expr)
+		if !ok {
+			return nil, fmt.Errorf("%s: cannot encode value", strings.Join(e.path, "."))
+		}
+		if err := setCUE(&file.Decls, e.path, expr); err != nil {
+			return nil, err
+		}
+	}
+	return format.Node(file)
+}
+
+// cueField returns the index of the field with the given name in a list of
+// CUE declarations, or -1 if there is none.
+func cueField(decls []ast.Decl, key string) int {
+	for i, decl := range decls {
+		if field, ok := decl.(*ast.Field); ok {
+			if name, _, err := ast.LabelName(field.Label); err == nil && name == key {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+// setCUE sets a nested field in a list of CUE declarations, creating
+// intermediate structs as needed. Replaced values keep the field's comments.
+func setCUE(decls *[]ast.Decl, path []string, value ast.Expr) error {
+	key := path[0]
+	var existing *ast.Field
+	if i := cueField(*decls, key); i >= 0 {
+		existing = (*decls)[i].(*ast.Field)
+	}
+
+	if len(path) > 1 {
+		if existing == nil {
+			existing = &ast.Field{Label: ast.NewStringLabel(key), Value: &ast.StructLit{}}
+			*decls = append(*decls, existing)
+		}
+		st, ok := existing.Value.(*ast.StructLit)
+		if !ok {
+			return fmt.Errorf("%s: expected a struct", key)
+		}
+		return setCUE(&st.Elts, path[1:], value)
+	}
+
+	if existing == nil {
+		*decls = append(*decls, &ast.Field{Label: ast.NewStringLabel(key), Value: value})
+		return nil
+	}
+	existing.Value = value
+	return nil
+}
+
+// removeCUE removes a nested field from a list of CUE declarations, along
+// with structs left empty. Returns false if the field isn't there.
+func removeCUE(decls *[]ast.Decl, path []string) bool {
+	i := cueField(*decls, path[0])
+	if i < 0 {
+		return false
+	}
+	if len(path) > 1 {
+		st, ok := (*decls)[i].(*ast.Field).Value.(*ast.StructLit)
+		if !ok || !removeCUE(&st.Elts, path[1:]) {
+			return false
+		}
+		if len(st.Elts) > 0 {
+			return true
+		}
+	}
+	*decls = slices.Delete(*decls, i, i+1)
+	return true
+}
+
+// editJSON applies edits to a JSON object. JSON has no comments, and keys
+// are written in sorted order.
+func editJSON(data []byte, edits []configEdit) ([]byte, error) {
+	doc := map[string]any{}
+	if len(bytes.TrimSpace(data)) > 0 {
+		if err := json.Unmarshal(data, &doc); err != nil {
+			return nil, err
+		}
+	}
+	for _, e := range edits {
+		if e.remove {
+			if !removeJSON(doc, e.path) {
+				return nil, errNotSet(e.path)
+			}
+			continue
+		}
+		m := doc
+		for _, key := range e.path[:len(e.path)-1] {
+			next, ok := m[key].(map[string]any)
+			if !ok {
+				if _, exists := m[key]; exists {
+					return nil, fmt.Errorf("%s: expected an object", key)
+				}
+				next = map[string]any{}
+				m[key] = next
+			}
+			m = next
+		}
+		m[e.path[len(e.path)-1]] = e.value
+	}
+	src, err := json.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append(src, '\n'), nil
+}
+
+// removeJSON removes a nested key from a JSON object, along with objects
+// left empty. Returns false if the key isn't there.
+func removeJSON(m map[string]any, path []string) bool {
+	value, ok := m[path[0]]
+	if !ok {
+		return false
+	}
+	if len(path) > 1 {
+		child, ok := value.(map[string]any)
+		if !ok || !removeJSON(child, path[1:]) {
+			return false
+		}
+		if len(child) > 0 {
+			return true
+		}
+	}
+	delete(m, path[0])
+	return true
+}
+
+// configLayer is a named config file that ConfigSet and ConfigUnset can
+// write to.
+type configLayer struct {
+	name string
+	path string
+}
+
+// layersTag lists the config layers on a ConfigSet or ConfigUnset
+// command, e.g. `layers:"system=/etc/myapp.yaml,user=~/.myapp.yaml"`.
+const layersTag = "layers"
+
+// parseLayers parses a layers tag.
+func parseLayers(tag string) ([]configLayer, error) {
+	var layers []configLayer
+	for _, entry := range strings.Split(tag, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		name, path, ok := strings.Cut(entry, "=")
+		if !ok || name == "" || path == "" {
+			return nil, fmt.Errorf("invalid config layer %q, expected name=path", entry)
+		}
+		layers = append(layers, configLayer{name: name, path: path})
+	}
+	return layers, nil
+}
+
+// configTargetFile picks the file ConfigSet and ConfigUnset write to: the
+// --file flag, the named --layer, the first layer, or the last config file
+// named by the Config flag that isn't a glob pattern.
+func configTargetFile(ctx *kong.Context, file, layer string) (string, error) {
+	if file != "" {
+		return kong.ExpandPath(file), nil
+	}
+
+	var layers []configLayer
+	if node := ctx.Selected(); node != nil && node.Tag != nil {
+		var err error
+		if layers, err = parseLayers(node.Tag.Get(layersTag)); err != nil {
+			return "", err
+		}
+	}
+	if layer != "" {
+		names := make([]string, len(layers))
+		for i, l := range layers {
+			if l.name == layer {
+				return kong.ExpandPath(l.path), nil
+			}
+			names[i] = l.name
+		}
+		if len(names) == 0 {
+			return "", fmt.Errorf("unknown config layer %q, no layers are defined", layer)
+		}
+		return "", fmt.Errorf("unknown config layer %q (expected one of %s)", layer, strings.Join(names, ", "))
+	}
+	if len(layers) > 0 {
+		return kong.ExpandPath(layers[0].path), nil
+	}
+
+	paths := configFlagPaths(ctx)
+	for i := len(paths) - 1; i >= 0; i-- {
+		if !strings.ContainsAny(paths[i], "*?[{") {
+			return kong.ExpandPath(paths[i]), nil
+		}
+	}
+	return "", fmt.Errorf("no config file to write to, use --file")
+}
+
+// ConfigSet is a Kong command that sets a key in a config file, like
+// `git config`. The key and value are validated against the CLI's schema
+// before anything is written, and YAML and CUE files keep their comments.
+//
+// The file is chosen with --file, or with --layer from the layers listed in
+// the command's layers tag. Without either, the first layer is used, or the
+// last non-glob file of the Config flag.
+//
+// Usage:
+//
+//	type cli struct {
+//	    Config        kongcue.Config `default:"/etc/myapp.yaml,~/.myapp.yaml"`
+//	    ConfigCommand struct {
+//	        Set   kongcue.ConfigSet   `cmd:"" help:"Set a config key." layers:"user=~/.myapp.yaml,system=/etc/myapp.yaml"`
+//	        Unset kongcue.ConfigUnset `cmd:"" help:"Remove a config key." layers:"user=~/.myapp.yaml,system=/etc/myapp.yaml"`
+//	    } `cmd:"" name:"config"`
+//	}
+//
+// Running `./myapp config set agent.ca_url https://ca.example.com` writes
+// the key to ~/.myapp.yaml.
+type ConfigSet struct {
+	Key   string `arg:"" help:"Config key, e.g. agent.ca_url."`
+	Value string `arg:"" help:"Value, in the same syntax as the flag's command line value."`
+	File  string `help:"Config file to write." type:"path" placeholder:"FILE"`
+	Layer string `help:"Config layer to write, as listed in the command's layers tag." placeholder:"NAME"`
+}
+
+// SkipsConfig reports that the config isn't loaded for config set, which
+// edits the file itself.
+func (ConfigSet) SkipsConfig() bool {
+	return true
+}
+
+// BeforeApply sets the key. Like ConfigDoc, it runs before validation so
+// it works without required flags, and without loading a config that may
+// be broken.
+func (c *ConfigSet) BeforeApply(app *kong.Kong, ctx *kong.Context, schemaOpts *SchemaOptions) error {
+	file, err := configTargetFile(ctx, flagString(ctx, "file", c.File), flagString(ctx, "layer", c.Layer))
+	if err != nil {
+		return err
+	}
+	key, _ := positionalValue(ctx, "key").(string)
+	value, _ := positionalValue(ctx, "value").(string)
+	if err := SetConfigValue(app.Model, file, key, value, schemaOpts); err != nil {
+		return err
+	}
+	app.Exit(0)
+	return nil
+}
+
+// ConfigUnset is a Kong command that removes a key from a config file.
+// It chooses the file like ConfigSet.
+type ConfigUnset struct {
+	Key   string `arg:"" help:"Config key, e.g. agent.ca_url."`
+	File  string `help:"Config file to write." type:"path" placeholder:"FILE"`
+	Layer string `help:"Config layer to write, as listed in the command's layers tag." placeholder:"NAME"`
+}
+
+// SkipsConfig reports that the config isn't loaded for config unset, which
+// edits the file itself.
+func (ConfigUnset) SkipsConfig() bool {
+	return true
+}
+
+// BeforeApply removes the key.
+func (c *ConfigUnset) BeforeApply(app *kong.Kong, ctx *kong.Context) error {
+	file, err := configTargetFile(ctx, flagString(ctx, "file", c.File), flagString(ctx, "layer", c.Layer))
+	if err != nil {
+		return err
+	}
+	key, _ := positionalValue(ctx, "key").(string)
+	if err := UnsetConfigValue(file, key); err != nil {
+		return err
+	}
+	app.Exit(0)
+	return nil
+}
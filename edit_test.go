@@ -0,0 +1,230 @@
+package kongcue_test
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/alecthomas/kong"
+	kongcue "github.com/brianm/kongcue"
+)
+
+type editCLI struct {
+	Level  string         `enum:"debug,info" default:"info"`
+	Config kongcue.Config `default:"./config.yaml"`
+	Agent  struct {
+		CaURL string            `name:"ca-url" required:""`
+		Port  int               `cue:">=1 & <=65535"`
+		Hosts []string          `name:"hosts"`
+		Tags  map[string]string `name:"tags"`
+	} `cmd:""`
+	ConfigCmd struct {
+		Set   kongcue.ConfigSet   `cmd:"" layers:"user=user.yaml,system=system.cue"`
+		Unset kongcue.ConfigUnset `cmd:"" layers:"user=user.yaml,system=system.cue"`
+	} `cmd:"" name:"config"`
+}
+
+func editParser(t *testing.T, cli *editCLI) *kong.Kong {
+	t.Helper()
+	parser, err := kong.New(cli, kongcue.Options())
+	if err != nil {
+		t.Fatalf("failed to create parser: %v", err)
+	}
+	return parser
+}
+
+func readFile(t *testing.T, path string) string {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("failed to read %s: %v", path, err)
+	}
+	return string(data)
+}
+
+func TestSetConfigValue(t *testing.T) {
+	dir := t.TempDir()
+	path := kongcue.WriteConfig(t, dir, "config.yaml", ""+
+		"# Logging\n"+
+		"level: info # default level\n"+
+		"agent:\n"+
+		"  port: 80\n")
+
+	var cli editCLI
+	app := editParser(t, &cli).Model
+
+	steps := []struct{ key, value string }{
+		{"level", "debug"},
+		{"agent.ca_url", "https://ca.test"},
+		{"agent.port", "8443"},
+		{"agent.hosts", "a,b"},
+	}
+	for _, s := range steps {
+		if err := kongcue.SetConfigValue(app, path, s.key, s.value, nil); err != nil {
+			t.Fatalf("set %s: %v", s.key, err)
+		}
+	}
+
+	expected := "" +
+		"# Logging\n" +
+		"level: debug # default level\n" +
+		"agent:\n" +
+		"  port: 8443\n" +
+		"  ca_url: https://ca.test\n" +
+		"  hosts:\n" +
+		"    - a\n" +
+		"    - b\n"
+	if got := readFile(t, path); got != expected {
+		t.Errorf("got:\n%s\nwant:\n%s", got, expected)
+	}
+}
+
+func TestSetConfigValue_Rejected(t *testing.T) {
+	var cli editCLI
+	app := editParser(t, &cli).Model
+	path := filepath.Join(t.TempDir(), "config.yaml")
+
+	tests := []struct {
+		key, value, want string
+	}{
+		{"agent.bogus", "x", `unknown config key "agent.bogus"`},
+		{"agent", "x", "agent is a section"},
+		{"level", "trace", "must be one of debug, info"},
+		{"agent.port", "70000", "agent.port"},
+		{"agent.port", "eighty", "agent.port"},
+	}
+	for _, tt := range tests {
+		err := kongcue.SetConfigValue(app, path, tt.key, tt.value, nil)
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("set %s=%s: expected error containing %q, got %v", tt.key, tt.value, tt.want, err)
+		}
+	}
+	if _, err := os.Stat(path); err == nil {
+		t.Error("rejected values should not create the file")
+	}
+}
+
+func TestSetConfigValue_AllowUnknownFields(t *testing.T) {
+	var cli editCLI
+	app := editParser(t, &cli).Model
+	path := filepath.Join(t.TempDir(), "config.yaml")
+
+	opts := &kongcue.SchemaOptions{AllowUnknownPaths: []string{"plugins"}}
+	if err := kongcue.SetConfigValue(app, path, "plugins.foo.enabled", "yes", opts); err != nil {
+		t.Fatalf("expected unknown key to be allowed: %v", err)
+	}
+	if got := readFile(t, path); got != "plugins:\n  foo:\n    enabled: \"yes\"\n" {
+		t.Errorf("unexpected file:\n%s", got)
+	}
+}
+
+func TestSetConfigValue_EmptyYAML(t *testing.T) {
+	var cli editCLI
+	app := editParser(t, &cli).Model
+	dir := t.TempDir()
+
+	tests := []struct {
+		name, content, want string
+	}{
+		{"comments.yaml", "# Log level\n# level: info\n", "# Log level\n# level: info\nlevel: debug\n"},
+		{"document.yaml", "---\n", "level: debug\n"},
+	}
+	for _, tt := range tests {
+		path := kongcue.WriteConfig(t, dir, tt.name, tt.content)
+		if err := kongcue.SetConfigValue(app, path, "level", "debug", nil); err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if got := readFile(t, path); got != tt.want {
+			t.Errorf("%s: got:\n%s\nwant:\n%s", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestUnsetConfigValue(t *testing.T) {
+	dir := t.TempDir()
+	path := kongcue.WriteConfig(t, dir, "config.cue", ""+
+		"// Logging\n"+
+		"level: \"debug\"\n"+
+		"agent: {\n"+
+		"\tport: 80\n"+
+		"}\n")
+
+	if err := kongcue.UnsetConfigValue(path, "agent.port"); err != nil {
+		t.Fatalf("unset: %v", err)
+	}
+	if got := readFile(t, path); got != "// Logging\nlevel: \"debug\"\n" {
+		t.Errorf("expected empty agent section to be removed, got:\n%s", got)
+	}
+
+	err := kongcue.UnsetConfigValue(path, "agent.port")
+	if err == nil || !strings.Contains(err.Error(), "agent.port is not set") {
+		t.Errorf("expected not set error, got %v", err)
+	}
+}
+
+func TestConfigSetCommand_Layers(t *testing.T) {
+	dir := t.TempDir()
+	t.Chdir(dir)
+	// A broken config must not stop the commands that fix it
+	kongcue.WriteConfig(t, dir, "config.yaml", "level: [")
+
+	run := func(args ...string) {
+		t.Helper()
+		var cli editCLI
+		var stdout bytes.Buffer
+		exited := -1
+		parser, err := kong.New(&cli, kongcue.Options(),
+			kong.Writers(&stdout, &stdout),
+			kong.Exit(func(code int) {
+				exited = code
+				panic("exit")
+			}))
+		if err != nil {
+			t.Fatalf("failed to create parser: %v", err)
+		}
+		func() {
+			defer func() { recover() }()
+			if _, err := parser.Parse(args); err != nil {
+				t.Fatalf("%v: %v", args, err)
+			}
+		}()
+		if exited != 0 {
+			t.Fatalf("%v: expected exit status 0, got %d", args, exited)
+		}
+	}
+
+	run("config", "set", "agent.port", "8080")
+	run("config", "set", "--layer", "system", "level", "debug")
+	run("config", "set", "--file", "other.json", "agent.ca_url", "https://x")
+
+	if got := readFile(t, "user.yaml"); got != "agent:\n  port: 8080\n" {
+		t.Errorf("unexpected user layer:\n%s", got)
+	}
+	if got := readFile(t, "system.cue"); got != "level: \"debug\"\n" {
+		t.Errorf("unexpected system layer:\n%s", got)
+	}
+	if got := readFile(t, "other.json"); !strings.Contains(got, `"ca_url": "https://x"`) {
+		t.Errorf("unexpected --file output:\n%s", got)
+	}
+
+	run("config", "unset", "--layer", "user", "agent.port")
+	if got := readFile(t, "user.yaml"); got != "{}\n" {
+		t.Errorf("expected empty user layer, got:\n%s", got)
+	}
+}
+
+func TestConfigSetCommand_NotInSchema(t *testing.T) {
+	var cli editCLI
+	app := editParser(t, &cli).Model
+
+	src, err := kongcue.GenerateJSONSchema(app, nil)
+	if err != nil {
+		t.Fatalf("failed to generate schema: %v", err)
+	}
+	if strings.Contains(string(src), `"config"`) {
+		t.Errorf("config command group should not appear in the schema:\n%s", src)
+	}
+}
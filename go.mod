@@ -6,6 +6,8 @@ require (
 	cuelang.org/go v0.15.1
 	github.com/alecthomas/kong v1.13.0
 	github.com/bmatcuk/doublestar/v4 v4.9.1
+	github.com/hexops/gotextdiff v1.0.3
+	go.yaml.in/yaml/v3 v3.0.4
 )
 
 require (
@@ -15,7 +17,6 @@ require (
 	github.com/mitchellh/go-wordwrap v1.0.1 // indirect
 	github.com/pelletier/go-toml/v2 v2.2.4 // indirect
 	github.com/protocolbuffers/txtpbfmt v0.0.0-20251124094003-fcb97cc64c7b // indirect
-	go.yaml.in/yaml/v3 v3.0.4 // indirect
 	golang.org/x/net v0.47.0 // indirect
 	golang.org/x/text v0.31.0 // indirect
 	google.golang.org/protobuf v1.36.10 // indirect
@@ -0,0 +1,59 @@
+package kongcue
+
+import (
+	"maps"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/alecthomas/kong"
+)
+
+// Test helpers shared by the tests of this package and of kongcue_test.
+
+// WriteConfig writes a file into dir and returns its path.
+func WriteConfig(t *testing.T, dir, name, content string) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// ParseWithConfig writes config to config.yaml in a temporary working
+// directory, where a Config flag defaulting to ./config.yaml finds it, and
+// parses args into cli.
+func ParseWithConfig(t *testing.T, cli any, config string, options []kong.Option, args ...string) (*kong.Context, error) {
+	t.Helper()
+	dir := t.TempDir()
+	t.Chdir(dir)
+	WriteConfig(t, dir, "config.yaml", config)
+
+	parser, err := kong.New(cli, options...)
+	if err != nil {
+		t.Fatalf("failed to create parser: %v", err)
+	}
+	return parser.Parse(args)
+}
+
+// restoreRegistries restores the global migrations and sections to their
+// state before the test when it finishes.
+func restoreRegistries(t *testing.T) {
+	t.Helper()
+	migrationsMu.RLock()
+	savedMigrations := maps.Clone(migrations)
+	migrationsMu.RUnlock()
+	registeredSectionsMu.RLock()
+	savedSections := maps.Clone(registeredSections)
+	registeredSectionsMu.RUnlock()
+
+	t.Cleanup(func() {
+		migrationsMu.Lock()
+		migrations = savedMigrations
+		migrationsMu.Unlock()
+		registeredSectionsMu.Lock()
+		registeredSections = savedSections
+		registeredSectionsMu.Unlock()
+	})
+}
@@ -0,0 +1,254 @@
+package kongcue
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"cuelang.org/go/cue"
+	"cuelang.org/go/cue/errors"
+	"cuelang.org/go/cue/token"
+	"github.com/alecthomas/kong"
+)
+
+// IssueKind classifies a config problem.
+type IssueKind string
+
+const (
+	// IssueUnknownKey is a key that doesn't correspond to any flag or command.
+	IssueUnknownKey IssueKind = "unknown-key"
+	// IssueTypeMismatch is a value of the wrong type, like a string for an int flag.
+	IssueTypeMismatch IssueKind = "type-mismatch"
+	// IssueMissingRequired is a required flag missing from the config.
+	IssueMissingRequired IssueKind = "missing-required"
+	// IssueConflict is a key set to different values in two config files.
+	IssueConflict IssueKind = "conflict"
+	// IssueInvalidValue is a value rejected by a constraint, like a cue:"" tag.
+	IssueInvalidValue IssueKind = "invalid-value"
+	// IssueDeprecatedKey is a deprecated or renamed key; see Warning.
+	IssueDeprecatedKey IssueKind = "deprecated-key"
+	// IssueInvalidFile is a config file that couldn't be read or parsed.
+	IssueInvalidFile IssueKind = "invalid-file"
+)
+
+// Issue is a single config problem, positioned in the config file that
+// caused it.
+type Issue struct {
+	Kind     IssueKind `json:"kind"`
+	Path     string    `json:"path,omitempty"`     // config path, e.g. "agent.ca_url"
+	File     string    `json:"file,omitempty"`     // config file, if known
+	Line     int       `json:"line,omitempty"`     // 1-based line in File
+	Column   int       `json:"column,omitempty"`   // 1-based column in File
+	Flag     string    `json:"flag,omitempty"`     // flag the key sets, e.g. "--ca-url"
+	Env      []string  `json:"env,omitempty"`      // environment variables of Flag
+	Command  string    `json:"command,omitempty"`  // command the key belongs to, e.g. "agent"
+	Expected string    `json:"expected,omitempty"` // schema type of the flag, e.g. "int & <=65535"
+	Message  string    `json:"message"`
+}
+
+// String formats the issue as "file:line:column: path: message", followed
+// by the flag and environment variables that set the same value, e.g.
+// "(flag agent --port, env APP_PORT)".
+func (i Issue) String() string {
+	var b strings.Builder
+	if i.File != "" {
+		fmt.Fprintf(&b, "%s:%d:%d: ", i.File, i.Line, i.Column)
+	}
+	if i.Path != "" {
+		b.WriteString(i.Path + ": ")
+	}
+	b.WriteString(i.Message)
+	var origin []string
+	if usage := i.flagUsage(); usage != "" {
+		origin = append(origin, "flag "+usage)
+	} else if i.Command != "" {
+		origin = append(origin, "command "+i.Command)
+	}
+	if len(i.Env) > 0 {
+		origin = append(origin, "env "+strings.Join(i.Env, " or "))
+	}
+	if len(origin) > 0 {
+		fmt.Fprintf(&b, " (%s)", strings.Join(origin, ", "))
+	}
+	return b.String()
+}
+
+// flagUsage returns the issue's flag as it's used on the command line,
+// after its command, e.g. "agent --port", or "" if there's no flag.
+func (i Issue) flagUsage() string {
+	if i.Flag == "" {
+		return ""
+	}
+	return strings.TrimSpace(i.Command + " " + i.Flag)
+}
+
+// Hints added after config errors of some kinds.
+const (
+	requiredHint   = "Hint: Required fields must be provided in the config file"
+	unknownKeyHint = "Hint: Check that all config keys correspond to valid CLI flags"
+)
+
+// issueHints returns hints for the kinds of issues found, if any.
+func issueHints(issues []Issue) []string {
+	var required, unknown bool
+	for _, issue := range issues {
+		required = required || issue.Kind == IssueMissingRequired
+		unknown = unknown || (issue.Kind == IssueUnknownKey && !strings.Contains(issue.Message, "did you mean"))
+	}
+	var hints []string
+	if required {
+		hints = append(hints, requiredHint)
+	}
+	if unknown {
+		hints = append(hints, unknownKeyHint)
+	}
+	return hints
+}
+
+// ValidationError is returned when a config doesn't match the schema. Its
+// message is meant for people; Issues lists the same problems for tools
+// and tests, and the error marshals to JSON as {"issues": [...]}.
+//
+// Use errors.As to get it from the error returned by Kong:
+//
+//	var verr *kongcue.ValidationError
+//	if errors.As(err, &verr) {
+//	    for _, issue := range verr.Issues { ... }
+//	}
+type ValidationError struct {
+	Issues []Issue
+}
+
+// newValidationError converts a validation error to a ValidationError,
+// using the application model and schema options to find the flag each
+// config key sets and its type. app and opts may be nil.
+func newValidationError(app *kong.Application, opts *schemaOptions, err error) *ValidationError {
+	return &ValidationError{Issues: configIssues(app, opts, err)}
+}
+
+// Error lists the issues one per line, followed by hints for common
+// mistakes.
+func (e *ValidationError) Error() string {
+	lines := make([]string, 0, len(e.Issues))
+	for _, issue := range e.Issues {
+		lines = append(lines, issue.String())
+	}
+	return strings.Join(append(lines, issueHints(e.Issues)...), "\n")
+}
+
+// MarshalJSON renders the error as {"issues": [...]}.
+func (e *ValidationError) MarshalJSON() ([]byte, error) {
+	issues := e.Issues
+	if issues == nil {
+		issues = []Issue{}
+	}
+	return json.Marshal(struct {
+		Issues []Issue `json:"issues"`
+	}{issues})
+}
+
+// configIssues splits an error into one issue per underlying CUE error,
+// each with the flag, environment variables and command of its key.
+// Positions in the generated schema are skipped in favor of the config
+// file position, and duplicate errors are reported once. Errors that don't
+// come from CUE, such as YAML syntax errors, become a single issue.
+func configIssues(app *kong.Application, opts *schemaOptions, err error) []Issue {
+	if err == nil {
+		return nil
+	}
+	var cueErr errors.Error
+	if !errors.As(err, &cueErr) {
+		return []Issue{{Kind: IssueInvalidFile, Message: err.Error()}}
+	}
+
+	var tree *configSection
+	if app != nil {
+		tree = buildConfigTree(app, opts)
+	}
+	var issues []Issue
+	seen := make(map[string]bool)
+	for _, e := range errors.Errors(err) {
+		format, args := e.Msg()
+		issue := Issue{
+			Kind:    issueKind(e),
+			Path:    strings.Join(unquotedPath(e.Path()), "."),
+			Message: fmt.Sprintf(format, args...),
+		}
+		for _, pos := range append([]token.Pos{e.Position()}, e.InputPositions()...) {
+			if file := pos.Filename(); file != "" && !isSchemaFile(file) {
+				issue.File, issue.Line, issue.Column = file, pos.Line(), pos.Column()
+				break
+			}
+		}
+		if section, flag := tree.lookup(unquotedPath(e.Path())); section != nil {
+			issue.Command = section.command()
+			if flag != nil {
+				issue.Flag = "--" + flag.Name
+				issue.Env = flag.Envs
+				issue.Expected, _ = exprString(flagType(flag, tree.opts))
+			}
+		}
+		if key := string(issue.Kind) + issue.String(); !seen[key] {
+			seen[key] = true
+			issues = append(issues, issue)
+		}
+	}
+	return issues
+}
+
+// issueKind classifies a CUE error by its message format.
+func issueKind(e errors.Error) IssueKind {
+	if _, ok := e.(*unknownKeyError); ok {
+		return IssueUnknownKey
+	}
+	format, _ := e.Msg()
+	switch {
+	case format == "field not allowed":
+		return IssueUnknownKey
+	case strings.Contains(format, "mismatched types"):
+		return IssueTypeMismatch
+	case strings.HasPrefix(format, "incomplete value"), strings.Contains(format, "required but not present"):
+		return IssueMissingRequired
+	case strings.HasPrefix(format, "conflicting values") && !inSchema(e):
+		// Both values come from config files rather than the schema
+		return IssueConflict
+	case len(e.Path()) == 0:
+		return IssueInvalidFile
+	default:
+		return IssueInvalidValue
+	}
+}
+
+// inSchema reports whether any of an error's positions are in a schema.
+func inSchema(e errors.Error) bool {
+	for _, pos := range append([]token.Pos{e.Position()}, e.InputPositions()...) {
+		if isSchemaFile(pos.Filename()) {
+			return true
+		}
+	}
+	return false
+}
+
+// isSchemaFile reports whether a position's filename belongs to a schema
+// rather than a config file: the generated schema, or a pseudo file like
+// "<field:>" for constraints from the struct tags of a Go type.
+func isSchemaFile(name string) bool {
+	return name == generatedSchemaFilename || strings.HasPrefix(name, "<")
+}
+
+// unquotedPath returns the labels of a CUE error path, which are quoted
+// where needed, like ["agent", "\"ca-url\""].
+func unquotedPath(path []string) []string {
+	if len(path) == 0 {
+		return nil
+	}
+	labels := make([]string, len(path))
+	for i, label := range path {
+		labels[i] = label
+		// List indexes and other non-string labels are kept as they are
+		if sels := cue.ParsePath(label).Selectors(); len(sels) == 1 && sels[0].LabelType() == cue.StringLabel {
+			labels[i] = sels[0].Unquoted()
+		}
+	}
+	return labels
+}
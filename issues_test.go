@@ -0,0 +1,213 @@
+package kongcue_test
+
+import (
+	"cmp"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/alecthomas/kong"
+	kongcue "github.com/brianm/kongcue"
+)
+
+type issuesCLI struct {
+	Config kongcue.Config `default:"./config.yaml"`
+	Name   string         `name:"name" env:"APP_NAME"`
+	Agent  struct {
+		CaURL string `name:"ca-url" aliases:"ca"`
+		Port  int    `name:"port" cue:"<=65535"`
+	} `cmd:""`
+	Server struct {
+		Key string `name:"key" required:""`
+	} `cmd:""`
+}
+
+// validationError parses a command with a config file and returns the
+// resulting ValidationError.
+func validationError(t *testing.T, config string, args ...string) *kongcue.ValidationError {
+	t.Helper()
+	if len(args) == 0 {
+		args = []string{"agent"}
+	}
+	_, err := kongcue.ParseWithConfig(t, &issuesCLI{}, config, []kong.Option{kongcue.Options()}, args...)
+	var verr *kongcue.ValidationError
+	if !errors.As(err, &verr) {
+		t.Fatalf("expected a ValidationError, got %T: %v", err, err)
+	}
+	return verr
+}
+
+func TestValidationError_Kinds(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  string
+		command string
+		want    kongcue.Issue
+	}{
+		{
+			name:   "unknown key",
+			config: "agent:\n  ca_url: x\n  ca-ur: y\n",
+			want: kongcue.Issue{
+				Kind: kongcue.IssueUnknownKey, Path: `agent.ca-ur`, Line: 3, Column: 3, Command: "agent",
+				Message: "unknown key; did you mean ca_url?",
+			},
+		},
+		{
+			name:   "type mismatch",
+			config: "agent:\n  ca_url: x\n  port: eighty\n",
+			want: kongcue.Issue{
+				Kind: kongcue.IssueTypeMismatch, Path: "agent.port", Line: 3, Column: 9, Flag: "--port", Command: "agent",
+			},
+		},
+		{
+			name:   "invalid value",
+			config: "agent:\n  ca_url: x\n  port: 70000\n",
+			want: kongcue.Issue{
+				Kind: kongcue.IssueInvalidValue, Path: "agent.port", Line: 3, Column: 9, Flag: "--port", Command: "agent",
+			},
+		},
+		{
+			name:    "missing required",
+			config:  "server: {}\n",
+			command: "server",
+			want: kongcue.Issue{
+				Kind: kongcue.IssueMissingRequired, Path: "server.key", Flag: "--key", Command: "server",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			verr := validationError(t, tt.config, cmp.Or(tt.command, "agent"))
+			if len(verr.Issues) != 1 {
+				t.Fatalf("expected one issue, got %+v", verr.Issues)
+			}
+			got := verr.Issues[0]
+			if got.Kind != tt.want.Kind || got.Path != tt.want.Path || got.Flag != tt.want.Flag || got.Command != tt.want.Command {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+			if tt.want.Line != 0 && (!strings.HasSuffix(got.File, "config.yaml") || got.Line != tt.want.Line || got.Column != tt.want.Column) {
+				t.Errorf("unexpected position %s:%d:%d", got.File, got.Line, got.Column)
+			}
+			if tt.want.Message != "" && got.Message != tt.want.Message {
+				t.Errorf("unexpected message %q", got.Message)
+			}
+		})
+	}
+}
+
+func TestValidationError_AliasFlag(t *testing.T) {
+	verr := validationError(t, "agent:\n  ca: 1\n")
+	if len(verr.Issues) != 1 || verr.Issues[0].Flag != "--ca-url" || verr.Issues[0].Kind != kongcue.IssueTypeMismatch {
+		t.Errorf("expected a type mismatch for --ca-url, got %+v", verr.Issues)
+	}
+}
+
+func TestValidationError_Conflict(t *testing.T) {
+	dir := t.TempDir()
+	a := kongcue.WriteConfig(t, dir, "a.yaml", "name: alice\n")
+	b := kongcue.WriteConfig(t, dir, "b.yaml", "name: bob\n")
+
+	var cli issuesCLI
+	parser, err := kong.New(&cli, kongcue.Options())
+	if err != nil {
+		t.Fatalf("failed to create parser: %v", err)
+	}
+	_, err = kongcue.Validate(parser.Model, []string{a, b}, nil)
+	var verr *kongcue.ValidationError
+	if !errors.As(err, &verr) {
+		t.Fatalf("expected a ValidationError, got %T: %v", err, err)
+	}
+	if len(verr.Issues) != 1 || verr.Issues[0].Kind != kongcue.IssueConflict || verr.Issues[0].Flag != "--name" {
+		t.Fatalf("expected a conflict for --name, got %+v", verr.Issues)
+	}
+	if env := verr.Issues[0].Env; len(env) != 1 || env[0] != "APP_NAME" {
+		t.Errorf("expected the flag's env var, got %v", env)
+	}
+	if msg := verr.Error(); !strings.Contains(msg, "(flag --name, env APP_NAME)") {
+		t.Errorf("expected the message to name the flag and env var, got %q", msg)
+	}
+}
+
+func TestValidationError_JSON(t *testing.T) {
+	verr := validationError(t, "agent:\n  ca_url: x\n  port: 70000\n")
+
+	data, err := json.Marshal(verr)
+	if err != nil {
+		t.Fatalf("failed to marshal: %v", err)
+	}
+	var doc struct {
+		Issues []map[string]any `json:"issues"`
+	}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		t.Fatalf("invalid JSON: %v\n%s", err, data)
+	}
+	if len(doc.Issues) != 1 {
+		t.Fatalf("expected one issue, got %s", data)
+	}
+	issue := doc.Issues[0]
+	if issue["kind"] != "invalid-value" || issue["path"] != "agent.port" || issue["flag"] != "--port" || issue["line"] != float64(3) {
+		t.Errorf("unexpected issue JSON: %s", data)
+	}
+}
+
+func TestValidationError_Message(t *testing.T) {
+	verr := validationError(t, "agent:\n  ca_url: x\n  bogus: 1\n")
+	if msg := verr.Error(); !strings.Contains(msg, "agent.bogus: unknown key (command agent)") || !strings.Contains(msg, "config.yaml:3:3") {
+		t.Errorf("expected a human readable message, got %q", msg)
+	}
+}
+
+func TestValidationError_MessageFlag(t *testing.T) {
+	verr := validationError(t, "agent:\n  ca_url: x\n  port: 70000\n")
+	if msg := verr.Error(); !strings.HasSuffix(msg, "agent.port: invalid value 70000 (out of bound <=65535) (flag agent --port)") {
+		t.Errorf("expected the message to name the flag, got %q", msg)
+	}
+}
+
+func TestValidationError_ConflictOnParse(t *testing.T) {
+	dir := t.TempDir()
+	a := kongcue.WriteConfig(t, dir, "a.yaml", "name: alice\n")
+	b := kongcue.WriteConfig(t, dir, "b.yaml", "name: bob\n")
+
+	var cli issuesCLI
+	parser, err := kong.New(&cli, kongcue.Options())
+	if err != nil {
+		t.Fatalf("failed to create parser: %v", err)
+	}
+	_, err = parser.Parse([]string{"--config", a + "," + b, "agent"})
+	var verr *kongcue.ValidationError
+	if !errors.As(err, &verr) {
+		t.Fatalf("expected a ValidationError, got %T: %v", err, err)
+	}
+	if len(verr.Issues) != 1 || verr.Issues[0].Kind != kongcue.IssueConflict || verr.Issues[0].Flag != "--name" {
+		t.Errorf("expected a conflict for --name, got %+v", verr.Issues)
+	}
+}
+
+type verbosity string
+
+func TestValidationError_ExpectedUsesOptions(t *testing.T) {
+	dir := t.TempDir()
+	path := kongcue.WriteConfig(t, dir, "config.yaml", "verbosity: 5\n")
+
+	var cli struct {
+		Config    kongcue.Config `default:"./config.yaml"`
+		Verbosity verbosity      `name:"verbosity"`
+	}
+	parser, err := kong.New(&cli, kongcue.WithType(reflect.TypeFor[verbosity](), kongcue.TypeMapping{
+		Schema: `"quiet" | "loud"`,
+	}))
+	if err != nil {
+		t.Fatalf("failed to create parser: %v", err)
+	}
+	_, err = parser.Parse([]string{"--config", path})
+	var verr *kongcue.ValidationError
+	if !errors.As(err, &verr) {
+		t.Fatalf("expected a ValidationError, got %T: %v", err, err)
+	}
+	if len(verr.Issues) == 0 || verr.Issues[0].Expected != `"quiet" | "loud"` {
+		t.Errorf("expected the application's type mapping, got %+v", verr.Issues)
+	}
+}
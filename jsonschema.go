@@ -0,0 +1,210 @@
+package kongcue
+
+import (
+	"encoding/json"
+	"math"
+	"reflect"
+	"slices"
+
+	"github.com/alecthomas/kong"
+)
+
+// jsonSchemaDialect is the JSON Schema draft the generated schemas declare.
+const jsonSchemaDialect = "https://json-schema.org/draft/2020-12/schema"
+
+// GenerateJSONSchema creates a JSON Schema (draft 2020-12) from a Kong
+// application model, for editors and tools that don't understand CUE.
+// It mirrors GenerateSchemaFile: each command becomes a definition under
+// $defs, help text becomes descriptions, and objects reject unknown keys
+// unless opts allows them there. opts may be nil.
+//
+// Reference the published schema from YAML config files to get completion
+// in editors using the YAML language server:
+//
+//	# yaml-language-server: $schema=https://example.com/myapp.schema.json
+func GenerateJSONSchema(app *kong.Application, opts *SchemaOptions) ([]byte, error) {
+	src, err := json.MarshalIndent(jsonSchemaDocument(buildConfigTree(app, opts.toInternal())), "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append(src, '\n'), nil
+}
+
+// jsonSchemaDocument converts a config section to a standalone JSON Schema
+// document, with the section's descendants as definitions.
+func jsonSchemaDocument(section *configSection) map[string]any {
+	defs := map[string]any{}
+	doc := jsonSchemaSection(section, defs)
+	doc["$schema"] = jsonSchemaDialect
+	if len(section.path) == 0 {
+		doc["title"] = section.node.Name + " configuration"
+	} else if section.node.Help != "" {
+		doc["description"] = section.node.Help
+	}
+	if len(defs) > 0 {
+		doc["$defs"] = defs
+	}
+	return doc
+}
+
+// jsonSchemaSection converts a config section to a JSON Schema object,
+// adding the definitions of its subcommands to defs.
+func jsonSchemaSection(section *configSection, defs map[string]any) map[string]any {
+	properties := map[string]any{}
+	required := []string{}
+
+	for _, flag := range section.flags {
+		key := kebabToSnake(flag.Name)
+		properties[key] = jsonSchemaFlag(flag, section.opts)
+		if flag.Required && len(flag.Aliases) == 0 {
+			required = append(required, key)
+		}
+		// Old names from aliases are accepted, but marked deprecated
+		for _, alias := range flag.Aliases {
+			prop := jsonSchemaFlag(flag, section.opts)
+			prop["description"] = "Deprecated: renamed to " + key
+			prop["deprecated"] = true
+			properties[kebabToSnake(alias)] = prop
+		}
+	}
+
+	for _, child := range section.children {
+		defName := commandDefName(child.path)
+		defs[defName] = jsonSchemaSection(child, defs)
+		prop := map[string]any{"$ref": "#/$defs/" + defName}
+		if child.node.Help != "" {
+			prop["description"] = child.node.Help
+		}
+		properties[child.path[len(child.path)-1]] = prop
+	}
+
+	// Versioned configs may name the version they were written for
+	if latest := section.opts.latestConfigVersion(); latest > 0 && len(section.path) == 0 {
+		properties[versionKey] = map[string]any{
+			"type":        "integer",
+			"minimum":     0,
+			"maximum":     latest,
+			"description": "Config file format version, used to upgrade old configs.",
+		}
+	}
+
+	// Go-typed sections are only described by the CUE schema
+	for _, key := range append(slices.Clone(section.sections), section.extra...) {
+		properties[key] = map[string]any{}
+	}
+
+	schema := map[string]any{
+		"type":       "object",
+		"properties": properties,
+	}
+	if len(required) > 0 {
+		schema["required"] = required
+	}
+	if !section.open {
+		schema["additionalProperties"] = false
+	}
+	return schema
+}
+
+// jsonSchemaFlag returns the JSON Schema for a flag, including its
+// description, enum values and default.
+func jsonSchemaFlag(flag *kong.Flag, opts *schemaOptions) map[string]any {
+	schema := jsonSchemaValue(flag.Value, opts)
+	if doc := flagDocComment(flag); doc != "" {
+		schema["description"] = doc
+	}
+	if flag.Tag.Has(deprecatedTag) {
+		schema["deprecated"] = true
+	}
+	if flag.Enum != "" {
+		enum := []any{}
+		for _, e := range flag.EnumSlice() {
+			enum = append(enum, scalarConfigValue(enumType(flag.Value), e, opts))
+		}
+		if items, ok := schema["items"].(map[string]any); ok {
+			items["enum"] = enum
+		} else {
+			schema["enum"] = enum
+		}
+	}
+	if val, ok := flagDefault(flag, opts); ok {
+		schema["default"] = val
+	}
+	return schema
+}
+
+// enumType returns the Go type enum values of a flag are written as.
+func enumType(v *kong.Value) reflect.Type {
+	if v.IsSlice() {
+		return v.Target.Type().Elem()
+	}
+	return v.Target.Type()
+}
+
+// jsonSchemaValue returns the JSON Schema for a flag's value.
+func jsonSchemaValue(v *kong.Value, opts *schemaOptions) map[string]any {
+	if m, ok := opts.typeMapping(v.Target.Type()); ok {
+		return m.jsonSchema()
+	}
+	switch {
+	case v.IsSlice():
+		return map[string]any{
+			"type":  "array",
+			"items": jsonSchemaType(v.Target.Type().Elem(), opts),
+		}
+	case v.IsMap():
+		schema := map[string]any{
+			"type":                 "object",
+			"additionalProperties": jsonSchemaType(v.Target.Type().Elem(), opts),
+		}
+		if pattern := mapKeyPattern(v.Target.Type().Key()); pattern != "" {
+			schema["propertyNames"] = map[string]any{"pattern": pattern}
+		}
+		return schema
+	case v.IsCounter():
+		return map[string]any{"type": "integer", "minimum": 0}
+	case v.IsBool():
+		return map[string]any{"type": "boolean"}
+	}
+	return jsonSchemaType(v.Target.Type(), opts)
+}
+
+// jsonSchemaType returns the JSON Schema for a Go type.
+func jsonSchemaType(typ reflect.Type, opts *schemaOptions) map[string]any {
+	if m, ok := opts.typeMapping(typ); ok {
+		return m.jsonSchema()
+	}
+	switch typ.Kind() {
+	case reflect.String:
+		return map[string]any{"type": "string"}
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}
+	case reflect.Int, reflect.Int64:
+		return map[string]any{"type": "integer"}
+	case reflect.Uint, reflect.Uint64:
+		return map[string]any{"type": "integer", "minimum": 0}
+	case reflect.Int8, reflect.Int16, reflect.Int32:
+		bits := typ.Bits()
+		return map[string]any{
+			"type":    "integer",
+			"minimum": -(int64(1) << (bits - 1)),
+			"maximum": int64(1)<<(bits-1) - 1,
+		}
+	case reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		return map[string]any{
+			"type":    "integer",
+			"minimum": 0,
+			"maximum": uint64(1)<<typ.Bits() - 1,
+		}
+	case reflect.Float32:
+		return map[string]any{
+			"type":    "number",
+			"minimum": -math.MaxFloat32,
+			"maximum": math.MaxFloat32,
+		}
+	case reflect.Float64:
+		return map[string]any{"type": "number"}
+	default:
+		return map[string]any{}
+	}
+}
@@ -0,0 +1,162 @@
+package kongcue_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/alecthomas/kong"
+	kongcue "github.com/brianm/kongcue"
+)
+
+type jsonSchemaCLI struct {
+	Name    string        `help:"Who to greet" default:"world"`
+	Level   string        `help:"Log level" enum:"debug,info,warn" default:"info"`
+	Retries uint8         `help:"Retry count"`
+	Timeout time.Duration `help:"Request timeout" default:"30s"`
+	Tags    []string      `help:"Tags to apply" default:"a,b"`
+	Agent   struct {
+		CaURL string                `name:"ca-url" help:"CA URL" required:""`
+		Ports map[int]string        `name:"ports"`
+		TLS   struct{ Cert string } `cmd:"" help:"TLS settings"`
+	} `cmd:"" help:"Run the agent"`
+	ConfigDoc kongcue.ConfigDoc `cmd:"config-doc"`
+}
+
+func generateJSONSchema(t *testing.T, cli any) map[string]any {
+	t.Helper()
+	parser, err := kong.New(cli)
+	if err != nil {
+		t.Fatalf("failed to create parser: %v", err)
+	}
+	src, err := kongcue.GenerateJSONSchema(parser.Model, nil)
+	if err != nil {
+		t.Fatalf("failed to generate JSON schema: %v", err)
+	}
+	var doc map[string]any
+	if err := json.Unmarshal(src, &doc); err != nil {
+		t.Fatalf("generated JSON schema is not valid JSON: %v\n%s", err, src)
+	}
+	return doc
+}
+
+// lookup walks a decoded JSON document by keys.
+func lookup(doc any, keys ...string) any {
+	for _, key := range keys {
+		m, ok := doc.(map[string]any)
+		if !ok {
+			return nil
+		}
+		doc = m[key]
+	}
+	return doc
+}
+
+func TestGenerateJSONSchema_Document(t *testing.T) {
+	var cli jsonSchemaCLI
+	doc := generateJSONSchema(t, &cli)
+
+	if got := doc["$schema"]; got != "https://json-schema.org/draft/2020-12/schema" {
+		t.Errorf("expected draft 2020-12 dialect, got %v", got)
+	}
+	if got := doc["additionalProperties"]; got != false {
+		t.Errorf("expected closed root object, got %v", got)
+	}
+	if got := lookup(doc, "properties", "agent", "$ref"); got != "#/$defs/Agent" {
+		t.Errorf("expected agent to reference #/$defs/Agent, got %v", got)
+	}
+	if got := lookup(doc, "properties", "agent", "description"); got != "Run the agent" {
+		t.Errorf("expected command help as description, got %v", got)
+	}
+	if got := lookup(doc, "$defs", "Agent", "properties", "tls", "$ref"); got != "#/$defs/AgentTls" {
+		t.Errorf("expected nested command definition, got %v", got)
+	}
+	if got := lookup(doc, "$defs", "Agent", "required"); !reflect.DeepEqual(got, []any{"ca_url"}) {
+		t.Errorf("expected required ca_url, got %v", got)
+	}
+	if _, ok := lookup(doc, "properties", "config_doc").(map[string]any); ok {
+		t.Error("config-doc command should not appear in the schema")
+	}
+}
+
+func TestGenerateJSONSchema_Properties(t *testing.T) {
+	var cli jsonSchemaCLI
+	doc := generateJSONSchema(t, &cli)
+
+	tests := []struct {
+		path []string
+		want any
+	}{
+		{[]string{"properties", "name", "description"}, "Who to greet"},
+		{[]string{"properties", "name", "default"}, "world"},
+		{[]string{"properties", "level", "enum"}, []any{"debug", "info", "warn"}},
+		{[]string{"properties", "retries", "maximum"}, float64(255)},
+		{[]string{"properties", "retries", "minimum"}, float64(0)},
+		{[]string{"properties", "timeout", "default"}, "30s"},
+		{[]string{"properties", "timeout", "type"}, []any{"string", "integer"}},
+		{[]string{"properties", "tags", "items", "type"}, "string"},
+		{[]string{"properties", "tags", "default"}, []any{"a", "b"}},
+		{[]string{"$defs", "Agent", "properties", "ports", "propertyNames", "pattern"}, "^-?[0-9]+$"},
+		{[]string{"$defs", "Agent", "properties", "ports", "additionalProperties", "type"}, "string"},
+	}
+
+	for _, tt := range tests {
+		got := lookup(doc, tt.path...)
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%v: got %#v, want %#v", tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestGenerateJSONSchema_AllowUnknownFields(t *testing.T) {
+	var cli jsonSchemaCLI
+	var buf bytes.Buffer
+	cli.ConfigDoc.Output = &buf
+
+	parser, err := kong.New(&cli, kongcue.AllowUnknownFields("agent", "extra"))
+	if err != nil {
+		t.Fatalf("failed to create parser: %v", err)
+	}
+	if _, err := parser.Parse([]string{"config-doc", "--format", "jsonschema"}); err != nil {
+		t.Fatalf("failed to parse: %v", err)
+	}
+
+	var doc map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &doc); err != nil {
+		t.Fatalf("invalid JSON: %v", err)
+	}
+	if got := doc["additionalProperties"]; got != false {
+		t.Errorf("root should stay closed, got %v", got)
+	}
+	if _, ok := lookup(doc, "$defs", "Agent").(map[string]any)["additionalProperties"]; ok {
+		t.Error("agent should allow additional properties")
+	}
+	if got := lookup(doc, "properties", "extra"); !reflect.DeepEqual(got, map[string]any{}) {
+		t.Errorf("expected free-form extra property, got %v", got)
+	}
+}
+
+func TestGenerateJSONSchema_Options(t *testing.T) {
+	var cli jsonSchemaCLI
+	parser, err := kong.New(&cli, kongcue.Options())
+	if err != nil {
+		t.Fatalf("failed to create parser: %v", err)
+	}
+	src, err := kongcue.GenerateJSONSchema(parser.Model, &kongcue.SchemaOptions{AllowUnknownPaths: []string{"agent"}})
+	if err != nil {
+		t.Fatalf("failed to generate JSON schema: %v", err)
+	}
+
+	var doc map[string]any
+	if err := json.Unmarshal(src, &doc); err != nil {
+		t.Fatalf("invalid JSON: %v", err)
+	}
+	if got := doc["additionalProperties"]; got != false {
+		t.Errorf("root should stay closed, got %v", got)
+	}
+	if _, ok := lookup(doc, "$defs", "Agent").(map[string]any)["additionalProperties"]; ok {
+		t.Error("agent should allow additional properties")
+	}
+}
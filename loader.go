@@ -15,15 +15,65 @@ import (
 	"github.com/bmatcuk/doublestar/v4"
 )
 
+// Loaded is the config loaded by Config. It is always bound for Kong's
+// dependency injection, even when no config file was found, so commands can
+// receive it along with the merged cue.Value:
+//
+//	func (a *AgentCmd) Run(cfg *kongcue.Loaded) error {
+//	    for _, file := range cfg.Files { ... }
+//	}
+type Loaded struct {
+	// Value is the merged config. Once validated, it's unified with Schema.
+	// It's an empty struct if no config file was loaded.
+	Value cue.Value
+
+	// Files lists the config files loaded, in the order they were unified.
+	Files []string
+
+	// FileValues holds the value of each loaded file, after migration.
+	FileValues map[string]cue.Value
+
+	// Schema is the schema the config was validated against. It doesn't
+	// exist if no config file was loaded, or if the config was skipped by
+	// a ConfigCommand.
+	Schema cue.Value
+}
+
+// Source returns the config file and line that set a config path, such as
+// "agent.ca_url". ok is false if no config file sets it.
+func (l *Loaded) Source(path string) (file string, line int, ok bool) {
+	val := l.Value.LookupPath(cue.ParsePath(path))
+	if !val.Exists() {
+		return "", 0, false
+	}
+	pos := configPos(val)
+	if !pos.IsValid() {
+		return "", 0, false
+	}
+	return pos.Filename(), pos.Line(), true
+}
+
 // LoadAndUnifyPaths loads multiple config files and unifies them into a single CUE value.
 // Supports glob patterns and mixed file types (.cue, .yaml, .yml, .json).
 // Missing files are silently skipped. Returns error if files have conflicting values.
+// Each file is brought up to the latest config_version first; see Migrate.
 //
 // The ~ character is expanded to the user's home directory.
 func LoadAndUnifyPaths(patterns []string) (cue.Value, error) {
+	loaded, err := loadConfig(patterns, nil)
+	if err != nil {
+		return cue.Value{}, err
+	}
+	return loaded.Value, nil
+}
+
+// loadConfig loads and unifies config files like LoadAndUnifyPaths,
+// keeping track of the files loaded and the value of each. Files are
+// migrated with the application's migrations in opts, which may be nil.
+func loadConfig(patterns []string, opts *schemaOptions) (*Loaded, error) {
 	ctx := cuecontext.New()
+	loaded := &Loaded{FileValues: make(map[string]cue.Value)}
 	var values []cue.Value
-	var loadedPaths []string
 
 	for _, pattern := range patterns {
 		// Expand ~ to home directory
@@ -47,20 +97,30 @@ func LoadAndUnifyPaths(patterns []string) (cue.Value, error) {
 		for _, path := range matches {
 			val, err := loadSingleFile(ctx, path)
 			if err != nil {
-				return cue.Value{}, err
+				return nil, err
 			}
 			if !val.Exists() {
 				continue // Skip unreadable files
 			}
+			migrated, version, err := migrateConfig(val, opts)
+			if err != nil {
+				return nil, fmt.Errorf("failed to migrate %s: %w", path, err)
+			}
+			if version < opts.latestConfigVersion() {
+				migrated = withSourcePositions(migrated, val)
+			}
+			val = migrated
 
 			values = append(values, val)
-			loadedPaths = append(loadedPaths, path)
+			loaded.Files = append(loaded.Files, path)
+			loaded.FileValues[path] = val
 		}
 	}
 
 	if len(values) == 0 {
 		// No config files found - return empty value (not an error)
-		return ctx.CompileString("{}"), nil
+		loaded.Value = ctx.CompileString("{}")
+		return loaded, nil
 	}
 
 	// Unify all values
@@ -68,12 +128,12 @@ func LoadAndUnifyPaths(patterns []string) (cue.Value, error) {
 	for i, v := range values[1:] {
 		result = result.Unify(v)
 		if err := result.Err(); err != nil {
-			return cue.Value{}, fmt.Errorf("config conflict between %s and %s: %w",
-				loadedPaths[0], loadedPaths[i+1], err)
+			return nil, fmt.Errorf("config conflict between %s and %s: %w",
+				loaded.Files[0], loaded.Files[i+1], err)
 		}
 	}
-
-	return result, nil
+	loaded.Value = result
+	return loaded, nil
 }
 
 // loadSingleFile loads a single config file, detecting type by extension.
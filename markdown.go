@@ -0,0 +1,84 @@
+package kongcue
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"cuelang.org/go/cue/ast"
+	"cuelang.org/go/cue/format"
+)
+
+// writeMarkdown writes reference documentation for a config section and
+// its descendants as Markdown tables, one per section.
+func writeMarkdown(w io.Writer, root *configSection, opts *schemaOptions) error {
+	fmt.Fprintln(w, "# Configuration")
+	var err error
+	root.walk(func(section *configSection) {
+		if err != nil {
+			return
+		}
+		fmt.Fprintln(w)
+		if len(section.path) == 0 {
+			fmt.Fprintln(w, "## Global settings")
+		} else {
+			fmt.Fprintf(w, "## `%s`\n", section.dotPath())
+		}
+		if len(section.path) > 0 && section.node.Help != "" {
+			fmt.Fprintln(w)
+			fmt.Fprintln(w, section.node.Help)
+		}
+		if len(section.flags) == 0 && len(section.sections) == 0 && len(section.extra) == 0 {
+			return
+		}
+
+		fmt.Fprintln(w)
+		fmt.Fprintln(w, "| Key | Type | Default | Description |")
+		fmt.Fprintln(w, "|-----|------|---------|-------------|")
+		for _, flag := range section.flags {
+			typ, ferr := exprString(flagType(flag, opts))
+			if ferr != nil {
+				err = ferr
+				return
+			}
+			def := ""
+			if flag.HasDefault {
+				def = "`" + markdownEscape(flag.Default) + "`"
+			}
+			desc := markdownEscape(strings.Join(flagComments(flag), " "))
+			fmt.Fprintf(w, "| `%s` | `%s` | %s | %s |\n",
+				configKey(section, kebabToSnake(flag.Name)), markdownEscape(typ), def, desc)
+		}
+		for _, key := range section.sections {
+			path := configKey(section, key)
+			fmt.Fprintf(w, "| `%s` | `#%s` | | Section decoded into a Go type, see the CUE schema |\n", path, sectionDefName(path))
+		}
+		for _, key := range section.extra {
+			fmt.Fprintf(w, "| `%s` | `_` | | Free-form, not validated |\n", configKey(section, key))
+		}
+	})
+	return err
+}
+
+// configKey returns the full dotted config key for a field in a section.
+func configKey(section *configSection, name string) string {
+	if len(section.path) == 0 {
+		return name
+	}
+	return section.dotPath() + "." + name
+}
+
+// exprString formats a CUE expression on a single line.
+func exprString(expr ast.Expr) (string, error) {
+	src, err := format.Node(expr)
+	if err != nil {
+		return "", err
+	}
+	return strings.Join(strings.Fields(string(src)), " "), nil
+}
+
+// markdownEscape escapes characters that would break a Markdown table cell.
+func markdownEscape(s string) string {
+	s = strings.ReplaceAll(s, "|", `\|`)
+	return strings.ReplaceAll(s, "\n", " ")
+}
@@ -0,0 +1,476 @@
+package kongcue
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+
+	"cuelang.org/go/cue"
+	"cuelang.org/go/cue/ast"
+	"cuelang.org/go/cue/cuecontext"
+	"cuelang.org/go/cue/token"
+	"github.com/alecthomas/kong"
+	"github.com/bmatcuk/doublestar/v4"
+	"github.com/hexops/gotextdiff"
+	"github.com/hexops/gotextdiff/myers"
+	"github.com/hexops/gotextdiff/span"
+)
+
+// versionKey is the config key holding a config file's format version.
+const versionKey = "config_version"
+
+// migration upgrades a config from one version to a later one.
+type migration struct {
+	to int
+	fn func(cue.Value) (cue.Value, error)
+}
+
+var (
+	migrationsMu sync.RWMutex
+	migrations   = map[int]migration{} // keyed by the version migrated from
+)
+
+// Migrate registers a function that upgrades configs from version from to
+// version to, for when flags are renamed or restructured. Once a migration
+// is registered, config files may set a top level config_version key;
+// files without it are at version 0. When a config is loaded, each file is
+// brought up to the latest registered version by running the migrations in
+// order, before it is validated, so old configs keep working.
+//
+// fn receives the file's config without the config_version key and
+// returns the upgraded config. Values moved by fn keep their position in
+// the file, so relative paths are still resolved against it and errors
+// still point at it. Migrate is meant to be called from an init
+// function, and panics if to isn't after from or if a migration from the
+// same version is already registered. Use WithMigration to add a migration
+// to one application only.
+//
+// Example:
+//
+//	func init() {
+//	    // Version 1 renamed --name to --user
+//	    kongcue.Migrate(0, 1, func(v cue.Value) (cue.Value, error) {
+//	        var m map[string]any
+//	        if err := v.Decode(&m); err != nil {
+//	            return v, err
+//	        }
+//	        if name, ok := m["name"]; ok {
+//	            m["user"] = name
+//	            delete(m, "name")
+//	        }
+//	        return v.Context().Encode(m), nil
+//	    })
+//	}
+func Migrate(from, to int, fn func(cue.Value) (cue.Value, error)) {
+	if err := checkMigration(from, to, fn); err != nil {
+		panic(err.Error())
+	}
+	migrationsMu.Lock()
+	defer migrationsMu.Unlock()
+	if _, dup := migrations[from]; dup {
+		panic(fmt.Sprintf("kongcue: Migrate called twice for version %d", from))
+	}
+	migrations[from] = migration{to: to, fn: fn}
+}
+
+// WithMigration returns a Kong option that adds a migration like Migrate,
+// but only to the application it's passed to. It takes precedence over a
+// migration registered with Migrate from the same version.
+//
+//	kong.Parse(&cli, kongcue.WithMigration(0, 1, renameUser))
+//
+// Creating the parser fails if to isn't after from or if the option is
+// passed twice for from.
+func WithMigration(from, to int, fn func(cue.Value) (cue.Value, error)) kong.Option {
+	return schemaOption(func(opts *SchemaOptions) error {
+		if err := checkMigration(from, to, fn); err != nil {
+			return err
+		}
+		if _, dup := opts.migrations[from]; dup {
+			return fmt.Errorf("kongcue: migration from version %d added twice", from)
+		}
+		if opts.migrations == nil {
+			opts.migrations = map[int]migration{}
+		}
+		opts.migrations[from] = migration{to: to, fn: fn}
+		return nil
+	})
+}
+
+// checkMigration checks the arguments of Migrate and WithMigration.
+func checkMigration(from, to int, fn func(cue.Value) (cue.Value, error)) error {
+	if fn == nil {
+		return fmt.Errorf("kongcue: migration function from version %d is nil", from)
+	}
+	if from < 0 || to <= from {
+		return fmt.Errorf("kongcue: invalid migration from version %d to %d", from, to)
+	}
+	return nil
+}
+
+// migrationFrom returns the migration from a config version, if any: the
+// one added with WithMigration, or else the one registered with Migrate.
+func (opts *schemaOptions) migrationFrom(version int) (migration, bool) {
+	if opts != nil {
+		if m, ok := opts.migrations[version]; ok {
+			return m, true
+		}
+	}
+	migrationsMu.RLock()
+	defer migrationsMu.RUnlock()
+	m, ok := migrations[version]
+	return m, ok
+}
+
+// latestConfigVersion returns the version configs are migrated to, or 0
+// if there are no migrations.
+func (opts *schemaOptions) latestConfigVersion() int {
+	migrationsMu.RLock()
+	defer migrationsMu.RUnlock()
+	latest := 0
+	for _, m := range migrations {
+		latest = max(latest, m.to)
+	}
+	if opts != nil {
+		for _, m := range opts.migrations {
+			latest = max(latest, m.to)
+		}
+	}
+	return latest
+}
+
+// migrateConfig brings a config file's value up to the latest version and
+// removes its config_version key. It returns the version the file was at.
+// Values are returned unchanged if there are no migrations.
+func migrateConfig(val cue.Value, opts *schemaOptions) (cue.Value, int, error) {
+	latest := opts.latestConfigVersion()
+	if latest == 0 {
+		return val, 0, nil
+	}
+
+	version := 0
+	if v := val.LookupPath(cue.MakePath(cue.Str(versionKey))); v.Exists() {
+		n, err := v.Int64()
+		if err != nil {
+			return cue.Value{}, 0, fmt.Errorf("%s must be an integer", versionKey)
+		}
+		version = int(n)
+		val = withoutField(val, versionKey)
+	}
+	if version > latest {
+		return cue.Value{}, version, fmt.Errorf("%s %d is newer than the latest supported version %d", versionKey, version, latest)
+	}
+
+	for v := version; v < latest; {
+		m, ok := opts.migrationFrom(v)
+		if !ok {
+			return cue.Value{}, version, fmt.Errorf("no migration from %s %d", versionKey, v)
+		}
+		migrated, err := m.fn(val)
+		if err == nil {
+			err = migrated.Err()
+		}
+		if err != nil {
+			return cue.Value{}, version, fmt.Errorf("migrating from %s %d to %d: %w", versionKey, v, m.to, err)
+		}
+		val, v = migrated, m.to
+	}
+	return val, version, nil
+}
+
+// withoutField returns a struct value with one field left out, keeping the
+// order and source positions of the others.
+func withoutField(val cue.Value, name string) cue.Value {
+	out := val.Context().CompileString("{}")
+	iter, err := val.Fields(cue.Optional(true))
+	if err != nil {
+		return val
+	}
+	for iter.Next() {
+		if sel := iter.Selector(); sel.String() != name {
+			out = out.FillPath(cue.MakePath(sel), iter.Value())
+		}
+	}
+	return out
+}
+
+// withSourcePositions rebuilds a migrated config so that its values keep
+// the source positions they had in the original file. Migrations that
+// rebuild the config, e.g. through Go maps, drop positions, and without
+// them relative paths can't be resolved against the file and errors can't
+// point into it. Each scalar or list takes the position of the original
+// value at the same path, or else of the only original value equal to it,
+// as when a key was renamed. Values the migration made up are positioned at
+// the start of the file.
+func withSourcePositions(migrated, orig cue.Value) cue.Value {
+	origLeaves := map[string]cue.Value{}
+	walkLeaves(orig, cue.Path{}, func(path cue.Path, leaf cue.Value) {
+		origLeaves[path.String()] = leaf
+	})
+	start := configPos(orig)
+	if !start.IsValid() {
+		return migrated
+	}
+	start = start.File().Pos(0, token.NoRelPos)
+
+	out := migrated.Context().CompileString("{}")
+	walkLeaves(migrated, cue.Path{}, func(path cue.Path, leaf cue.Value) {
+		same, ok := origLeaves[path.String()]
+		if ok && same.Equals(leaf) {
+			out = out.FillPath(path, same)
+			return
+		}
+		pos := start
+		if ok {
+			pos = configPos(same)
+		} else if equal := equalLeaf(origLeaves, leaf); equal.Exists() {
+			pos = configPos(equal)
+		}
+		out = out.FillPath(path, positioned(leaf, pos))
+	})
+	return out
+}
+
+// walkLeaves calls fn for each scalar, list and empty struct in a config
+// value, with its path.
+func walkLeaves(val cue.Value, path cue.Path, fn func(cue.Path, cue.Value)) {
+	iter, err := val.Fields(cue.Optional(true))
+	if err != nil {
+		fn(path, val)
+		return
+	}
+	empty := true
+	for iter.Next() {
+		empty = false
+		sel := append(append([]cue.Selector{}, path.Selectors()...), iter.Selector())
+		walkLeaves(iter.Value(), cue.MakePath(sel...), fn)
+	}
+	if empty && len(path.Selectors()) > 0 {
+		fn(path, val)
+	}
+}
+
+// equalLeaf returns the only leaf equal to val, or a zero value if there
+// are none or several.
+func equalLeaf(leaves map[string]cue.Value, val cue.Value) cue.Value {
+	var found cue.Value
+	for _, leaf := range leaves {
+		if leaf.Equals(val) {
+			if found.Exists() {
+				return cue.Value{}
+			}
+			found = leaf
+		}
+	}
+	return found
+}
+
+// positioned returns a copy of a value with all of it at pos.
+func positioned(val cue.Value, pos token.Pos) cue.Value {
+	expr, ok := val.Syntax(cue.Final()).(ast.Expr)
+	if !ok || !pos.IsValid() {
+		return val
+	}
+	ast.Walk(expr, func(n ast.Node) bool {
+		ast.SetPos(n, pos)
+		return true
+	}, nil)
+	return val.Context().BuildExpr(expr)
+}
+
+// stampSample adds the latest config_version to a generated sample config,
+// so new config files aren't mistaken for version 0.
+func stampSample(src []byte, format string, opts *schemaOptions) ([]byte, error) {
+	latest := opts.latestConfigVersion()
+	if latest == 0 {
+		return src, nil
+	}
+	if format == "json" {
+		var doc map[string]any
+		if err := json.Unmarshal(src, &doc); err != nil {
+			return nil, err
+		}
+		doc[versionKey] = latest
+		out, err := json.MarshalIndent(doc, "", "  ")
+		if err != nil {
+			return nil, err
+		}
+		return append(out, '\n'), nil
+	}
+	syntax := yamlSyntax
+	if format == "cue" {
+		syntax = cueSyntax
+	}
+	var buf bytes.Buffer
+	writeComments(&buf, syntax, "", []string{"Config file format version, used to upgrade old configs."})
+	fmt.Fprintf(&buf, "%s: %d\n\n", versionKey, latest)
+	buf.Write(src)
+	return buf.Bytes(), nil
+}
+
+// migrateFile migrates a config file to the latest version and returns its
+// current and migrated content. The migrated content is nil if the file is
+// already up to date. Only the keys the migrations changed are edited, like
+// config set does, so comments elsewhere in the file are kept.
+func migrateFile(path string, opts *schemaOptions) (before, after []byte, err error) {
+	before, err = os.ReadFile(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	val, err := loadSingleFile(cuecontext.New(), path)
+	if err != nil {
+		return nil, nil, err
+	}
+	latest := opts.latestConfigVersion()
+	migrated, version, err := migrateConfig(val, opts)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to migrate %s: %w", path, err)
+	}
+	if version == latest {
+		return before, nil, nil
+	}
+
+	edits, err := migrationEdits(withoutField(val, versionKey), migrated)
+	if err == nil {
+		edits = append(edits, configEdit{path: []string{versionKey}, value: latest})
+		after, err = editConfig(path, before, edits)
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to update %s: %w", path, err)
+	}
+	return before, after, nil
+}
+
+// migrationEdits returns the edits that turn a config file's value into
+// its migrated value: the keys the migrations removed, followed by the
+// values they changed or added.
+func migrationEdits(orig, migrated cue.Value) ([]configEdit, error) {
+	origLeaves := map[string]cue.Value{}
+	walkLeaves(orig, cue.Path{}, func(path cue.Path, leaf cue.Value) {
+		origLeaves[path.String()] = leaf
+	})
+
+	var sets []configEdit
+	var err error
+	kept := map[string]bool{}
+	walkLeaves(migrated, cue.Path{}, func(path cue.Path, leaf cue.Value) {
+		kept[path.String()] = true
+		if same, ok := origLeaves[path.String()]; ok && same.Equals(leaf) {
+			return
+		}
+		var native any
+		if decodeErr := leaf.Decode(&native); decodeErr != nil && err == nil {
+			err = fmt.Errorf("%s: %w", path, decodeErr)
+		}
+		sets = append(sets, configEdit{path: pathLabels(path), value: native})
+	})
+
+	var edits []configEdit
+	walkLeaves(orig, cue.Path{}, func(path cue.Path, _ cue.Value) {
+		if !kept[path.String()] {
+			edits = append(edits, configEdit{path: pathLabels(path), remove: true})
+		}
+	})
+	return append(edits, sets...), err
+}
+
+// pathLabels returns the unquoted labels of a config path.
+func pathLabels(path cue.Path) []string {
+	var labels []string
+	for _, sel := range path.Selectors() {
+		if sel.LabelType() == cue.StringLabel {
+			labels = append(labels, sel.Unquoted())
+		} else {
+			labels = append(labels, sel.String())
+		}
+	}
+	return labels
+}
+
+// ConfigMigrate is a Kong command that rewrites config files to the latest
+// config_version using the migrations added with WithMigration or Migrate,
+// printing a diff of each change. Configs are migrated in memory whenever they are
+// loaded, so this is only needed to update the files themselves.
+//
+// The files to migrate are given as arguments; without arguments, the files
+// named by the Config flag are migrated. Only the keys the migrations
+// change are edited, so other keys keep their comments and order; comments
+// on moved or removed keys go with them. --dry-run shows the diffs without
+// writing anything.
+//
+// Usage:
+//
+//	type cli struct {
+//	    Config        kongcue.Config        `default:"~/.myapp.yaml"`
+//	    ConfigMigrate kongcue.ConfigMigrate `cmd:"config-migrate" help:"Upgrade config files to the latest version"`
+//	}
+type ConfigMigrate struct {
+	Paths  []string `arg:"" optional:"" help:"Config files or glob patterns to migrate. Defaults to the --config files." placeholder:"FILE"`
+	DryRun bool     `name:"dry-run" help:"Show the changes without writing them."`
+
+	// Output is the writer for the diffs. Defaults to the Kong
+	// application's stdout. Exposed for testing; when set, the command
+	// returns instead of exiting.
+	Output io.Writer `kong:"-"`
+}
+
+// SkipsConfig reports that the config isn't loaded for config-migrate,
+// since old configs don't match the schema until they're migrated.
+func (ConfigMigrate) SkipsConfig() bool {
+	return true
+}
+
+// BeforeApply migrates the config files. It runs before the config is
+// loaded and before validation, so required flags don't need to be set.
+func (c *ConfigMigrate) BeforeApply(app *kong.Kong, ctx *kong.Context, schemaOpts *SchemaOptions) error {
+	opts := schemaOpts.toInternal()
+	patterns := positionalStrings(ctx, "paths")
+	if len(patterns) == 0 {
+		patterns = configFlagPaths(ctx)
+	}
+	dryRun := flagBool(ctx, "dry-run", c.DryRun)
+
+	out := c.Output
+	if out == nil {
+		out = app.Stdout
+	}
+
+	migrated := 0
+	for _, pattern := range patterns {
+		matches, _ := doublestar.FilepathGlob(kong.ExpandPath(pattern))
+		for _, path := range matches {
+			before, after, err := migrateFile(path, opts)
+			if err != nil {
+				return err
+			}
+			if after == nil {
+				continue
+			}
+			fmt.Fprint(out, unifiedDiff(path, before, after))
+			if !dryRun {
+				if err := os.WriteFile(path, after, 0o644); err != nil {
+					return err
+				}
+			}
+			migrated++
+		}
+	}
+	if migrated == 0 {
+		fmt.Fprintf(out, "Config files are at %s %d\n", versionKey, opts.latestConfigVersion())
+	}
+
+	if c.Output == nil {
+		app.Exit(0)
+	}
+	return nil
+}
+
+// unifiedDiff returns a unified diff of two versions of a file, with three
+// lines of context around each change.
+func unifiedDiff(path string, a, b []byte) string {
+	edits := myers.ComputeEdits(span.URIFromPath(path), string(a), string(b))
+	return fmt.Sprint(gotextdiff.ToUnified(path, path+" (migrated)", string(a), edits))
+}
@@ -0,0 +1,406 @@
+package kongcue
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"cuelang.org/go/cue"
+	"cuelang.org/go/cue/format"
+	"github.com/alecthomas/kong"
+)
+
+type migrateCLI struct {
+	User          string        `name:"user"`
+	Config        Config        `default:"./config.yaml"`
+	ConfigMigrate ConfigMigrate `cmd:"config-migrate"`
+	Server        struct {
+		Port int `name:"port"`
+	} `cmd:""`
+}
+
+// renameKey returns a migration that moves a top level key to a new path.
+func renameKey(from, to string) func(cue.Value) (cue.Value, error) {
+	return func(v cue.Value) (cue.Value, error) {
+		old := v.LookupPath(cue.ParsePath(from))
+		if !old.Exists() {
+			return v, nil
+		}
+		return withoutField(v, from).FillPath(cue.ParsePath(to), old), nil
+	}
+}
+
+// registerMigrations registers the migrations for migrateCLI: version 1
+// renamed name to user, and version 2 moved port into the server section.
+func registerMigrations(t *testing.T) {
+	t.Helper()
+	restoreRegistries(t)
+	Migrate(0, 1, renameKey("name", "user"))
+	Migrate(1, 2, renameKey("port", "server.port"))
+}
+
+// withMigrations adds the migrations of registerMigrations to one
+// application.
+func withMigrations() []kong.Option {
+	return []kong.Option{
+		WithMigration(0, 1, renameKey("name", "user")),
+		WithMigration(1, 2, renameKey("port", "server.port")),
+	}
+}
+
+func TestMigrate_AppliedOnLoad(t *testing.T) {
+	dir := t.TempDir()
+
+	tests := []struct {
+		name, content string
+	}{
+		{"v0.yaml", "name: alice\nport: 8080\n"},
+		{"v1.yaml", "config_version: 1\nuser: alice\nport: 8080\n"},
+		{"v2.cue", "config_version: 2\nuser: \"alice\"\nserver: port: 8080\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := WriteConfig(t, dir, tt.name, tt.content)
+			var cli migrateCLI
+			parser, err := kong.New(&cli, withMigrations()...)
+			if err != nil {
+				t.Fatalf("failed to create parser: %v", err)
+			}
+			if _, err := parser.Parse([]string{"--config", path, "server"}); err != nil {
+				t.Fatalf("failed to parse: %v", err)
+			}
+			if cli.User != "alice" || cli.Server.Port != 8080 {
+				t.Errorf("expected migrated values, got user=%q port=%d", cli.User, cli.Server.Port)
+			}
+		})
+	}
+}
+
+func TestMigrate_Errors(t *testing.T) {
+	registerMigrations(t)
+	dir := t.TempDir()
+
+	tests := []struct {
+		content, want string
+	}{
+		{"config_version: 3\n", "config_version 3 is newer than the latest supported version 2"},
+		{"config_version: \"two\"\n", "config_version must be an integer"},
+	}
+	for _, tt := range tests {
+		path := WriteConfig(t, dir, "config.yaml", tt.content)
+		_, err := LoadAndUnifyPaths([]string{path})
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("expected error containing %q, got %v", tt.want, err)
+		}
+	}
+}
+
+type migratePathCLI struct {
+	Config Config `default:"./config.yaml"`
+	Agent  struct {
+		CA   string `name:"ca" type:"path"`
+		Port int    `name:"port"`
+	} `cmd:""`
+}
+
+// decodeMigration moves the top level ca_file and port keys into the agent
+// section by round-tripping through Go maps, which drops the values' source
+// positions.
+func decodeMigration(v cue.Value) (cue.Value, error) {
+	var m map[string]any
+	if err := v.Decode(&m); err != nil {
+		return v, err
+	}
+	agent := map[string]any{"ca": m["ca_file"], "port": m["port"]}
+	delete(m, "ca_file")
+	delete(m, "port")
+	m["agent"] = agent
+	return v.Context().Encode(m), nil
+}
+
+func TestMigrate_KeepsSourcePositions(t *testing.T) {
+	restoreRegistries(t)
+	Migrate(0, 1, decodeMigration)
+	dir := t.TempDir()
+	t.Chdir(t.TempDir())
+
+	path := WriteConfig(t, dir, "config.yaml", "ca_file: certs/ca.pem\nport: 8080\n")
+	var cli migratePathCLI
+	parser, err := kong.New(&cli, Options())
+	if err != nil {
+		t.Fatalf("failed to create parser: %v", err)
+	}
+	if _, err := parser.Parse([]string{"--config", path, "agent"}); err != nil {
+		t.Fatalf("failed to parse: %v", err)
+	}
+	if want := filepath.Join(dir, "certs", "ca.pem"); cli.Agent.CA != want {
+		t.Errorf("expected path relative to the config file %q, got %q", want, cli.Agent.CA)
+	}
+
+	// Errors in migrated values point at the original key
+	WriteConfig(t, dir, "config.yaml", "ca_file: certs/ca.pem\nport: eighty\n")
+	_, err = Validate(parser.Model, []string{path}, nil)
+	var verr *ValidationError
+	if !errors.As(err, &verr) || len(verr.Issues) != 1 {
+		t.Fatalf("expected one issue, got %v", err)
+	}
+	if issue := verr.Issues[0]; issue.File != path || issue.Line != 2 || issue.Path != "agent.port" {
+		t.Errorf("expected agent.port at %s:2, got %+v", path, issue)
+	}
+}
+
+func TestMigrate_MissingStep(t *testing.T) {
+	registerMigrations(t)
+	Migrate(5, 6, renameKey("a", "b"))
+
+	path := WriteConfig(t, t.TempDir(), "config.yaml", "config_version: 2\n")
+	_, err := LoadAndUnifyPaths([]string{path})
+	if err == nil || !strings.Contains(err.Error(), "no migration from config_version 2") {
+		t.Errorf("expected missing migration error, got %v", err)
+	}
+}
+
+func TestMigrate_InvalidRegistrations(t *testing.T) {
+	registerMigrations(t)
+	fn := renameKey("a", "b")
+
+	for name, register := range map[string]func(){
+		"duplicate": func() { Migrate(1, 3, fn) },
+		"backwards": func() { Migrate(4, 3, fn) },
+		"nil":       func() { Migrate(4, 5, nil) },
+	} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("%s: expected Migrate to panic", name)
+				}
+			}()
+			register()
+		}()
+	}
+}
+
+func TestWithMigration_Registered(t *testing.T) {
+	registerMigrations(t)
+	path := WriteConfig(t, t.TempDir(), "config.yaml", "name: alice\nport: 8080\n")
+
+	// Migrations added to the application replace registered ones from the
+	// same version, and extend the others
+	var cli migrateCLI
+	parser, err := kong.New(&cli,
+		WithMigration(1, 2, renameKey("port", "server.port")),
+		WithMigration(2, 3, renameKey("user", "name")),
+	)
+	if err != nil {
+		t.Fatalf("failed to create parser: %v", err)
+	}
+	if _, err := parser.Parse([]string{"--config", path, "server"}); err == nil || !strings.Contains(err.Error(), "name") {
+		t.Errorf("expected name to be unknown after migrating to version 3, got %v", err)
+	}
+
+	// Other applications only see the registered ones
+	parser, err = kong.New(&cli, Options())
+	if err != nil {
+		t.Fatalf("failed to create parser: %v", err)
+	}
+	if _, err := parser.Parse([]string{"--config", path, "server"}); err != nil {
+		t.Errorf("expected the registered migrations to apply: %v", err)
+	}
+}
+
+func TestWithMigration_Errors(t *testing.T) {
+	fn := renameKey("a", "b")
+	for name, options := range map[string][]kong.Option{
+		"duplicate": {WithMigration(1, 2, fn), WithMigration(1, 3, fn)},
+		"backwards": {WithMigration(4, 3, fn)},
+		"nil":       {WithMigration(4, 5, nil)},
+	} {
+		var cli migrateCLI
+		if _, err := kong.New(&cli, options...); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
+
+func TestMigrate_VersionInSchemaAndSample(t *testing.T) {
+	var cli migrateCLI
+	parser, err := kong.New(&cli, withMigrations()...)
+	if err != nil {
+		t.Fatalf("failed to create parser: %v", err)
+	}
+	ctx, err := parser.Parse([]string{"--config", filepath.Join(t.TempDir(), "none.yaml"), "server"})
+	if err != nil {
+		t.Fatalf("failed to parse: %v", err)
+	}
+	opts := schemaOptionsFrom(ctx)
+
+	schema, err := format.Node(GenerateSchemaFile(parser.Model, opts.toInternal()))
+	if err != nil {
+		t.Fatalf("failed to format schema: %v", err)
+	}
+	if !strings.Contains(string(schema), "config_version?: int & <=2") {
+		t.Errorf("expected config_version in schema, got:\n%s", schema)
+	}
+
+	src, err := GenerateJSONSchema(parser.Model, opts)
+	if err != nil {
+		t.Fatalf("failed to generate JSON schema: %v", err)
+	}
+	var doc struct {
+		Properties map[string]struct {
+			Type    string `json:"type"`
+			Maximum int    `json:"maximum"`
+		} `json:"properties"`
+	}
+	if err := json.Unmarshal(src, &doc); err != nil {
+		t.Fatalf("invalid JSON schema: %v", err)
+	}
+	if v := doc.Properties[versionKey]; v.Type != "integer" || v.Maximum != 2 {
+		t.Errorf("expected config_version in JSON schema, got:\n%s", src)
+	}
+
+	sample, err := GenerateSampleConfig(parser.Model, opts, "yaml")
+	if err != nil {
+		t.Fatalf("failed to generate sample: %v", err)
+	}
+	if !strings.Contains(string(sample), "\nconfig_version: 2\n") {
+		t.Errorf("expected sample to be stamped with the version, got:\n%s", sample)
+	}
+}
+
+func TestConfigMigrate_RewritesFiles(t *testing.T) {
+	dir := t.TempDir()
+	old := WriteConfig(t, dir, "old.yaml", "name: alice\nport: 8080\n")
+	current := WriteConfig(t, dir, "current.yaml", "config_version: 2\nuser: bob\n")
+
+	migrate := func(args ...string) string {
+		t.Helper()
+		var cli migrateCLI
+		var out bytes.Buffer
+		cli.ConfigMigrate.Output = &out
+		parser, err := kong.New(&cli, withMigrations()...)
+		if err != nil {
+			t.Fatalf("failed to create parser: %v", err)
+		}
+		if _, err := parser.Parse(append([]string{"config-migrate"}, args...)); err != nil {
+			t.Fatalf("failed to parse: %v", err)
+		}
+		return out.String()
+	}
+
+	// A dry run shows the diff without touching the file
+	diff := migrate("--dry-run", filepath.Join(dir, "*.yaml"))
+	expected := "" +
+		"--- " + old + "\n" +
+		"+++ " + old + " (migrated)\n" +
+		"@@ -1,2 +1,4 @@\n" +
+		"-name: alice\n" +
+		"-port: 8080\n" +
+		"+server:\n" +
+		"+  port: 8080\n" +
+		"+user: alice\n" +
+		"+config_version: 2\n"
+	if diff != expected {
+		t.Errorf("got diff:\n%s\nwant:\n%s", diff, expected)
+	}
+	if data, _ := os.ReadFile(old); string(data) != "name: alice\nport: 8080\n" {
+		t.Errorf("dry run should not write, got:\n%s", data)
+	}
+
+	migrate(old, current)
+	if data, _ := os.ReadFile(old); !strings.HasSuffix(string(data), "config_version: 2\n") {
+		t.Errorf("expected migrated file, got:\n%s", data)
+	}
+	if data, _ := os.ReadFile(current); string(data) != "config_version: 2\nuser: bob\n" {
+		t.Errorf("up to date file should not change, got:\n%s", data)
+	}
+
+	if out := migrate(old); out != "Config files are at config_version 2\n" {
+		t.Errorf("expected nothing left to migrate, got:\n%s", out)
+	}
+}
+
+func TestConfigMigrate_KeepsComments(t *testing.T) {
+	path := WriteConfig(t, t.TempDir(), "config.yaml", ""+
+		"# Written by hand\n"+
+		"config_version: 1\n"+
+		"# The operator\n"+
+		"user: alice # on call\n"+
+		"port: 8080\n")
+
+	var cli migrateCLI
+	cli.ConfigMigrate.Output = &bytes.Buffer{}
+	parser, err := kong.New(&cli, withMigrations()...)
+	if err != nil {
+		t.Fatalf("failed to create parser: %v", err)
+	}
+	if _, err := parser.Parse([]string{"config-migrate", path}); err != nil {
+		t.Fatalf("failed to parse: %v", err)
+	}
+	want := "" +
+		"# Written by hand\n" +
+		"config_version: 2\n" +
+		"# The operator\n" +
+		"user: alice # on call\n" +
+		"server:\n" +
+		"  port: 8080\n"
+	if data, _ := os.ReadFile(path); string(data) != want {
+		t.Errorf("got:\n%s\nwant:\n%s", data, want)
+	}
+}
+
+func TestUnifiedDiff_Context(t *testing.T) {
+	a := "a\nb\nc\nd\ne\nf\ng\nh\ni\nj\n"
+	b := "a\nB\nc\nd\ne\nf\ng\nh\ni\nJ\n"
+	expected := "" +
+		"--- x\n+++ x (migrated)\n" +
+		"@@ -1,5 +1,5 @@\n a\n-b\n+B\n c\n d\n e\n" +
+		"@@ -7,4 +7,4 @@\n g\n h\n i\n-j\n+J\n"
+	if got := unifiedDiff("x", []byte(a), []byte(b)); got != expected {
+		t.Errorf("got:\n%s\nwant:\n%s", got, expected)
+	}
+}
+
+func TestUnifiedDiff_HunkBoundaries(t *testing.T) {
+	header := "--- x\n+++ x (migrated)\n"
+	tests := []struct {
+		name, a, b, want string
+	}{
+		{
+			name: "six unchanged lines share a hunk",
+			a:    "a\nb\nc\nd\ne\nf\ng\nh\n",
+			b:    "A\nb\nc\nd\ne\nf\ng\nH\n",
+			want: "@@ -1,8 +1,8 @@\n-a\n+A\n b\n c\n d\n e\n f\n g\n-h\n+H\n",
+		},
+		{
+			name: "seven unchanged lines split hunks",
+			a:    "a\nb\nc\nd\ne\nf\ng\nh\ni\n",
+			b:    "A\nb\nc\nd\ne\nf\ng\nh\nI\n",
+			want: "@@ -1,4 +1,4 @@\n-a\n+A\n b\n c\n d\n" +
+				"@@ -6,4 +6,4 @@\n f\n g\n h\n-i\n+I\n",
+		},
+		{
+			name: "insertion at the end",
+			a:    "a\nb\n",
+			b:    "a\nb\nc\n",
+			want: "@@ -1,2 +1,3 @@\n a\n b\n+c\n",
+		},
+		{
+			name: "no trailing newline",
+			a:    "a\nb",
+			b:    "a\nc",
+			want: "@@ -1,2 +1,2 @@\n a\n-b\n\\ No newline at end of file\n+c\n\\ No newline at end of file\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := unifiedDiff("x", []byte(tt.a), []byte(tt.b)); got != header+tt.want {
+				t.Errorf("got:\n%s\nwant:\n%s", got, header+tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,208 @@
+package kongcue
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/alecthomas/kong"
+)
+
+// ANSI escape codes used by RenderError.
+const (
+	ansiReset   = "\x1b[0m"
+	ansiBoldRed = "\x1b[1;31m"
+	ansiBold    = "\x1b[1m"
+	ansiBlue    = "\x1b[1;34m"
+)
+
+// RenderError writes err to w for people to read. Each issue of a
+// ValidationError is shown with the offending line of its config file, a
+// caret under the bad key or value, the type the schema expects and the
+// flag the key sets:
+//
+//	error: agent.port: invalid value 70000 (out of bound <=65535)
+//	  --> config.yaml:3:9
+//	   |
+//	 3 |   port: 70000
+//	   |         ^^^^^ expected int & <=65535, set by agent --port
+//
+// With color, the output uses ANSI escape codes. Other errors are written
+// as a single "error: ..." line.
+func RenderError(w io.Writer, err error, color bool) {
+	if err == nil {
+		return
+	}
+	r := &errorRenderer{w: w, color: color, files: make(map[string][]string)}
+	var verr *ValidationError
+	if !errors.As(err, &verr) || len(verr.Issues) == 0 {
+		r.header("", err.Error())
+		return
+	}
+	for i, issue := range verr.Issues {
+		if i > 0 {
+			fmt.Fprintln(w)
+		}
+		r.issue(issue)
+	}
+	for _, hint := range issueHints(verr.Issues) {
+		fmt.Fprintln(w, hint)
+	}
+}
+
+// FatalIfErrorf is like kong's FatalIfErrorf, but renders config errors
+// with RenderError, in color when the application's stderr is a terminal:
+//
+//	parser := kong.Must(&cli, kongcue.Options())
+//	ctx, err := parser.Parse(os.Args[1:])
+//	kongcue.FatalIfErrorf(parser, err)
+func FatalIfErrorf(k *kong.Kong, err error) {
+	var verr *ValidationError
+	if !errors.As(err, &verr) {
+		k.FatalIfErrorf(err)
+		return
+	}
+	RenderError(k.Stderr, err, isTerminal(k.Stderr))
+	k.Exit(1)
+}
+
+// isTerminal reports whether w is a terminal that should get colored
+// output, following the NO_COLOR convention.
+func isTerminal(w io.Writer) bool {
+	f, ok := w.(*os.File)
+	if !ok || os.Getenv("NO_COLOR") != "" {
+		return false
+	}
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// errorRenderer writes issues, caching the lines of config files.
+type errorRenderer struct {
+	w     io.Writer
+	color bool
+	files map[string][]string
+}
+
+// paint wraps s in an ANSI style when color is enabled.
+func (r *errorRenderer) paint(style, s string) string {
+	if !r.color || s == "" {
+		return s
+	}
+	return style + s + ansiReset
+}
+
+// header writes the "error: path: message" line of an issue.
+func (r *errorRenderer) header(path, message string) {
+	if path != "" {
+		message = path + ": " + message
+	}
+	fmt.Fprintf(r.w, "%s %s\n", r.paint(ansiBoldRed, "error:"), r.paint(ansiBold, message))
+}
+
+// issue writes a single issue, with a snippet of its config file if the
+// file can still be read.
+func (r *errorRenderer) issue(issue Issue) {
+	r.header(issue.Path, issue.Message)
+
+	var notes []string
+	if issue.Expected != "" {
+		notes = append(notes, "expected "+issue.Expected)
+	}
+	if usage := issue.flagUsage(); usage != "" {
+		notes = append(notes, "set by "+usage)
+	}
+	if len(issue.Env) > 0 {
+		notes = append(notes, "or "+strings.Join(issue.Env, " or "))
+	}
+	note := strings.Join(notes, ", ")
+
+	if issue.File == "" {
+		if note != "" {
+			fmt.Fprintf(r.w, "   %s %s\n", r.paint(ansiBlue, "="), note)
+		}
+		return
+	}
+
+	width := len(strconv.Itoa(issue.Line))
+	pad := strings.Repeat(" ", width)
+	fmt.Fprintf(r.w, "%s%s %s:%d:%d\n", pad, r.paint(ansiBlue, " -->"), issue.File, issue.Line, issue.Column)
+
+	line, ok := r.line(issue.File, issue.Line)
+	if !ok {
+		if note != "" {
+			fmt.Fprintf(r.w, " %s %s %s\n", pad, r.paint(ansiBlue, "="), note)
+		}
+		return
+	}
+	gutter := r.paint(ansiBlue, " "+pad+" |")
+	fmt.Fprintln(r.w, gutter)
+	fmt.Fprintf(r.w, "%s %s\n", r.paint(ansiBlue, " "+strconv.Itoa(issue.Line)+" |"), line)
+
+	col := min(max(issue.Column, 1), len(line)+1)
+	carets := strings.Repeat("^", tokenLen(line[col-1:]))
+	if note != "" {
+		carets += " " + note
+	}
+	fmt.Fprintf(r.w, "%s %s%s\n", gutter, indentLike(line[:col-1]), r.paint(ansiBoldRed, carets))
+}
+
+// line returns a 1-based line of a file, reading the file on first use.
+func (r *errorRenderer) line(file string, n int) (string, bool) {
+	lines, ok := r.files[file]
+	if !ok {
+		if data, err := os.ReadFile(file); err == nil {
+			lines = strings.Split(strings.ReplaceAll(string(data), "\r\n", "\n"), "\n")
+		}
+		r.files[file] = lines
+	}
+	if n < 1 || n > len(lines) {
+		return "", false
+	}
+	return lines[n-1], true
+}
+
+// indentLike returns whitespace as wide as s, keeping its tabs so that a
+// caret lines up with the text above it.
+func indentLike(s string) string {
+	return strings.Map(func(r rune) rune {
+		if r == '\t' {
+			return r
+		}
+		return ' '
+	}, s)
+}
+
+// tokenLen returns the length of the key or value at the start of s: a
+// quoted string up to its closing quote, or text up to whitespace, a
+// separator or a key's colon. It is at least 1, so there's always a caret.
+func tokenLen(s string) int {
+	if s == "" {
+		return 1
+	}
+	if q := s[0]; q == '"' || q == '\'' {
+		for i := 1; i < len(s); i++ {
+			switch {
+			case s[i] == '\\' && q == '"':
+				i++
+			case s[i] == q:
+				return i + 1
+			}
+		}
+		return len(s)
+	}
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case ' ', '\t', ',', '}', ']':
+			return max(i, 1)
+		case ':':
+			if i+1 == len(s) || s[i+1] == ' ' || s[i+1] == '\t' {
+				return max(i, 1)
+			}
+		}
+	}
+	return len(s)
+}
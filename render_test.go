@@ -0,0 +1,96 @@
+package kongcue_test
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/alecthomas/kong"
+	kongcue "github.com/brianm/kongcue"
+)
+
+func TestRenderError_Snippet(t *testing.T) {
+	verr := validationError(t, "agent:\n  ca_url: x\n  port: 70000\n")
+
+	var buf bytes.Buffer
+	kongcue.RenderError(&buf, verr, false)
+	got := buf.String()
+	for _, want := range []string{
+		"error: agent.port: invalid value 70000 (out of bound <=65535)\n",
+		"config.yaml:3:9\n",
+		" 3 |   port: 70000\n",
+		"   |         ^^^^^ expected int & <=65535, set by agent --port\n",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("expected %q in:\n%s", want, got)
+		}
+	}
+	if strings.Contains(got, "\x1b[") {
+		t.Errorf("expected plain output, got:\n%s", got)
+	}
+}
+
+func TestRenderError_Key(t *testing.T) {
+	verr := validationError(t, "agent:\n  ca_url: x\n  \"ca-ur\": y\n")
+
+	var buf bytes.Buffer
+	kongcue.RenderError(&buf, verr, false)
+	if want := `   |   ^^^^^^^` + "\n"; !strings.Contains(buf.String(), want) {
+		t.Errorf("expected the caret under the quoted key, got:\n%s", buf.String())
+	}
+}
+
+func TestRenderError_Color(t *testing.T) {
+	verr := validationError(t, "agent:\n  ca_url: x\n  port: eighty\n")
+
+	var buf bytes.Buffer
+	kongcue.RenderError(&buf, verr, true)
+	if got := buf.String(); !strings.Contains(got, "\x1b[1;31m^^^^^^ expected int & <=65535, set by agent --port\x1b[0m") {
+		t.Errorf("expected colored carets, got:\n%q", got)
+	}
+}
+
+func TestRenderError_NoFile(t *testing.T) {
+	verr := validationError(t, "server: {}\n", "server")
+
+	var buf bytes.Buffer
+	kongcue.RenderError(&buf, verr, false)
+	want := "error: server.key: incomplete value string\n   = expected string, set by server --key\n" +
+		"Hint: Required fields must be provided in the config file\n"
+	if got := buf.String(); got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestRenderError_Plain(t *testing.T) {
+	var buf bytes.Buffer
+	kongcue.RenderError(&buf, errors.New("boom"), false)
+	if got := buf.String(); got != "error: boom\n" {
+		t.Errorf("unexpected output %q", got)
+	}
+}
+
+func TestFatalIfErrorf(t *testing.T) {
+	dir := t.TempDir()
+	t.Chdir(dir)
+	kongcue.WriteConfig(t, dir, "config.yaml", "agent:\n  port: 70000\n")
+
+	var cli issuesCLI
+	var stderr bytes.Buffer
+	exited := -1
+	parser, err := kong.New(&cli, kongcue.Options(), kong.Writers(os.Stdout, &stderr), kong.Exit(func(code int) { exited = code }))
+	if err != nil {
+		t.Fatalf("failed to create parser: %v", err)
+	}
+	_, err = parser.Parse([]string{"agent"})
+	kongcue.FatalIfErrorf(parser, err)
+
+	if exited != 1 {
+		t.Errorf("expected exit status 1, got %d", exited)
+	}
+	if got := stderr.String(); !strings.Contains(got, " 2 |   port: 70000\n") || strings.Contains(got, "\x1b[") {
+		t.Errorf("expected a plain snippet, got:\n%s", got)
+	}
+}
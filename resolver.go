@@ -2,9 +2,13 @@ package kongcue
 
 import (
 	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
 	"strings"
 
 	"cuelang.org/go/cue"
+	"cuelang.org/go/cue/cuecontext"
 	"cuelang.org/go/cue/errors"
 	"github.com/alecthomas/kong"
 )
@@ -12,6 +16,7 @@ import (
 // cueResolver implements kong.Resolver using direct CUE value lookups
 type cueResolver struct {
 	value cue.Value
+	opts  *schemaOptions // for type mappings added with WithType, may be nil
 }
 
 // NewResolver creates a Kong resolver backed by a CUE value.
@@ -31,22 +36,19 @@ func NewResolver(value cue.Value) kong.Resolver {
 
 type Config []string
 
-// isRunningConfigDoc checks if the selected command is a ConfigDoc command.
-// Used to skip config validation when generating schema documentation.
-func isRunningConfigDoc(ctx *kong.Context) bool {
-	if ctx.Selected() == nil {
-		return false
-	}
-	target := ctx.Selected().Target
-	if !target.IsValid() {
-		return false
-	}
-	return target.Type().String() == "kongcue.ConfigDoc"
+// skipsConfig checks if the selected command is a ConfigCommand that skips
+// the config. Used to skip loading the config when generating schema
+// documentation or sample configs, validating config files, or running
+// commands such as version that must work with a broken config.
+func skipsConfig(ctx *kong.Context) bool {
+	cmd, ok := configCommand(ctx.Selected())
+	return ok && cmd.SkipsConfig()
 }
 
 func (r Config) BeforeResolve(k *kong.Kong, ctx *kong.Context, trace *kong.Path, schemaOpts *SchemaOptions) error {
-	// Skip validation if the target command is ConfigDoc - it will handle its own exit
-	if isRunningConfigDoc(ctx) {
+	// Skip validation if the target command works on the config itself - it handles its own exit
+	if skipsConfig(ctx) {
+		bindLoaded(ctx, &Loaded{Value: cuecontext.New().CompileString("{}")})
 		return nil
 	}
 
@@ -55,10 +57,13 @@ func (r Config) BeforeResolve(k *kong.Kong, ctx *kong.Context, trace *kong.Path,
 	for i, path := range paths {
 		expanded[i] = kong.ExpandPath(path)
 	}
-	val, err := LoadAndUnifyPaths(expanded)
+	opts := schemaOpts.toInternal()
+	loaded, err := loadConfig(expanded, opts)
 	if err != nil {
-		return fmt.Errorf("unable to load config: %w", err)
+		// Conflicts between files are reported like validation errors
+		return newValidationError(k.Model, opts, err)
 	}
+	val := loaded.Value
 
 	// Check if any config was actually loaded (has fields)
 	// If no config files were found, skip validation and let Kong handle CLI flags
@@ -67,32 +72,79 @@ func (r Config) BeforeResolve(k *kong.Kong, ctx *kong.Context, trace *kong.Path,
 
 	if !hasConfig {
 		// No config loaded - just set up empty resolver, let Kong handle validation
-		ctx.AddResolver(&cueResolver{value: val})
+		bindLoaded(ctx, loaded)
+		ctx.AddResolver(&cueResolver{value: val, opts: opts})
 		return nil
 	}
 
 	// Generate schema and validate config early to report config errors clearly
-	opts := schemaOpts.toInternal()
-	schema, err := GenerateSchema(val.Context(), k.Model, opts)
+	schema, permissive, err := validationSchemas(val.Context(), k.Model, opts)
+	if err != nil {
+		return err
+	}
+	tree := buildConfigTree(k.Model, opts)
+	checked := val
+	scoped := schemaOpts != nil && schemaOpts.SelectedCommandOnly
+	if scoped {
+		checked = scopeConfig(val, tree, selectedCommandPath(ctx))
+	}
+	merged, errs := checkConfig(checked, schema, permissive, tree)
+	if errs != nil {
+		return newValidationError(k.Model, opts, errs)
+	}
+	if scoped {
+		// Resolve from the whole config, whose other sections may be invalid
+		merged = val.Unify(schema)
+	}
+	if err := handleWarnings(deprecationWarnings(k.Model, checked), schemaOpts, k.Stderr); err != nil {
+		return err
+	}
+
+	loaded.Value, loaded.Schema = merged, schema
+	bindLoaded(ctx, loaded)
+	ctx.AddResolver(&cueResolver{value: merged, opts: opts})
+	return nil
+}
+
+// bindLoaded binds the loaded config, and its merged value, for commands.
+func bindLoaded(ctx *kong.Context, loaded *Loaded) {
+	ctx.Bind(loaded)
+	ctx.Bind(loaded.Value)
+}
+
+// validationSchemas generates the schemas a config is checked against: the
+// strict schema, and a permissive one with the same structure but any
+// values, used to report unknown fields separately from type errors. The
+// permissive schema is not generated if unknown fields are allowed
+// everywhere.
+func validationSchemas(cctx *cue.Context, app *kong.Application, opts *schemaOptions) (schema, permissive cue.Value, err error) {
+	schema, err = GenerateSchema(cctx, app, opts)
 	if err != nil {
-		return fmt.Errorf("failed to generate config schema: %w", err)
+		return cue.Value{}, cue.Value{}, fmt.Errorf("failed to generate config schema: %w", err)
+	}
+	if opts.allowAll {
+		return schema, cue.Value{}, nil
 	}
+	// Inherit path-specific allows and sections
+	permissiveOpts := *opts
+	permissiveOpts.permissiveTypes = true
+	permissive, err = GenerateSchema(cctx, app, &permissiveOpts)
+	if err != nil {
+		return cue.Value{}, cue.Value{}, fmt.Errorf("failed to generate config schema: %w", err)
+	}
+	return schema, permissive, nil
+}
 
+// checkConfig validates a config against the schemas from validationSchemas
+// and returns it unified with the strict schema, along with all errors found.
+// Unknown keys are reported with suggestions of similar valid keys from the
+// config tree.
+func checkConfig(val, schema, permissive cue.Value, tree *configSection) (cue.Value, errors.Error) {
 	var allErrs errors.Error
 
 	// First pass: check for unknown fields using permissive types
-	// Skip if allowAll is set (unknown fields allowed everywhere)
-	if !opts.allowAll {
-		permissiveOpts := &schemaOptions{
-			permissiveTypes:   true,
-			allowUnknownPaths: opts.allowUnknownPaths, // Inherit path-specific allows
-			allowAll:          opts.allowAll,
-		}
-		permissiveSchema, err := GenerateSchema(val.Context(), k.Model, permissiveOpts)
-		if err != nil {
-			return fmt.Errorf("failed to generate config schema: %w", err)
-		}
-		if err := val.Unify(permissiveSchema).Validate(); err != nil {
+	if permissive.Exists() {
+		if err := val.Unify(permissive).Validate(); err != nil {
 			allErrs = errors.Append(allErrs, errors.Promote(err, ""))
 		}
 	}
@@ -104,13 +156,47 @@ func (r Config) BeforeResolve(k *kong.Kong, ctx *kong.Context, trace *kong.Path,
 		allErrs = errors.Append(allErrs, errors.Promote(err, ""))
 	}
 
-	if allErrs != nil {
-		return errors.New(filterErrorDetails(allErrs))
+	return merged, explainUnknownKeys(allErrs, schema, tree)
+}
+
+// scopeConfig returns the part of a config that applies to a command path:
+// everything but the sections of commands off the path. Unknown keys at
+// each level of the path are kept, so they are still reported.
+func scopeConfig(val cue.Value, section *configSection, path []string) cue.Value {
+	if len(path) == 0 {
+		return val
+	}
+	iter, err := val.Fields(cue.Optional(true))
+	if err != nil {
+		return val
 	}
+	out := val.Context().CompileString("{}")
+	for iter.Next() {
+		sel := iter.Selector()
+		var child *configSection
+		if sel.LabelType() == cue.StringLabel {
+			child = section.child(sel.Unquoted())
+		}
+		switch {
+		case child == nil:
+			out = out.FillPath(cue.MakePath(sel), iter.Value())
+		case sel.Unquoted() == path[0]:
+			out = out.FillPath(cue.MakePath(sel), scopeConfig(iter.Value(), child, path[1:]))
+		}
+	}
+	return out
+}
 
-	ctx.Bind(merged)
-	ctx.AddResolver(&cueResolver{value: merged})
-	return nil
+// selectedCommandPath returns the names of the selected command and its
+// parents, or nil if no command is selected.
+func selectedCommandPath(ctx *kong.Context) []string {
+	var path []string
+	for n := ctx.Selected(); n != nil; n = n.Parent {
+		if n.Type == kong.CommandNode && n.Name != "" {
+			path = append([]string{n.Name}, path...)
+		}
+	}
+	return path
 }
 
 func (r *cueResolver) Validate(app *kong.Application) error {
@@ -118,61 +204,57 @@ func (r *cueResolver) Validate(app *kong.Application) error {
 	return nil
 }
 
-// filterErrorDetails formats CUE errors, removing references to generated files
-// and adding helpful context for common error types.
-func filterErrorDetails(err error) string {
-	details := errors.Details(err, nil)
-	// Filter out lines referencing the generated schema
-	var filtered []string
-	hasIncomplete := false
-	hasNotAllowed := false
-	for line := range strings.SplitSeq(details, "\n") {
-		if strings.Contains(line, "generated-schema") {
-			continue
-		}
-		if strings.Contains(line, "incomplete value") {
-			hasIncomplete = true
-		}
-		if strings.Contains(line, "field not allowed") {
-			hasNotAllowed = true
-		}
-		filtered = append(filtered, line)
-	}
-	result := strings.Join(filtered, "\n")
-
-	// Add helpful hints based on error type
-	if hasIncomplete {
-		result += "\nHint: Required fields must be provided in the config file"
-	}
-	if hasNotAllowed {
-		result += "\nHint: Check that all config keys correspond to valid CLI flags"
+func (r *cueResolver) Resolve(ctx *kong.Context, parent *kong.Path, flag *kong.Flag) (any, error) {
+	val := lookupFlag(r.value, getCommandPath(parent), flag)
+	if !val.Exists() {
+		return nil, nil
 	}
-	return result
+	return resolveValue(val, flag, r.opts)
 }
 
-func (r *cueResolver) Resolve(ctx *kong.Context, parent *kong.Path, flag *kong.Flag) (any, error) {
-	// Build the config path from command context
-	cmdPath := getCommandPath(parent)
-
+// lookupFlag looks up the config value of a flag under a command path,
+// falling back to the flag's old names.
+func lookupFlag(config cue.Value, cmdPath []string, flag *kong.Flag) cue.Value {
 	// Normalize flag name: convert kebab-case to snake_case
-	flagName := strings.ReplaceAll(flag.Name, "-", "_")
+	flagName := kebabToSnake(flag.Name)
 
 	// Build full path: e.g., "agent.ca_url" or just "insecure" for globals
-	var cuePath string
-	if len(cmdPath) == 0 {
-		cuePath = flagName
-	} else {
-		cuePath = strings.Join(append(cmdPath, flagName), ".")
+	cuePath := strings.Join(append(append([]string{}, cmdPath...), flagName), ".")
+
+	val := config.LookupPath(cue.ParsePath(cuePath))
+	for _, alias := range flag.Aliases {
+		if val.Exists() {
+			break
+		}
+		val = config.LookupPath(cue.ParsePath(strings.Join(append(append([]string{}, cmdPath...), kebabToSnake(alias)), ".")))
 	}
+	return val
+}
 
-	// Look up the value in CUE
-	val := r.value.LookupPath(cue.ParsePath(cuePath))
-	if !val.Exists() {
-		return nil, nil
+// resolveValue converts a flag's config value into what is handed to Kong,
+// which parses it like a command line value. Returns nil to leave the flag
+// unset.
+func resolveValue(val cue.Value, flag *kong.Flag, opts *schemaOptions) (any, error) {
+	// Types with mappings may convert values themselves
+	if m, ok := opts.typeMapping(flag.Target.Type()); ok && m.Extract != nil {
+		return m.Extract(val)
+	}
+
+	// Maps are passed to Kong in its "key=value;..." syntax
+	if flag.IsMap() {
+		return extractMap(val, flag, opts)
+	}
+
+	if flag.IsSlice() {
+		return extractSlice(val, flag, opts)
 	}
 
 	// Extract the value based on type
-	return extractValue(val, flag.IsSlice())
+	v, err := extractValue(val)
+	if str, ok := v.(string); ok && isPathFlag(flag) {
+		v = rebasePath(str, val)
+	}
+	return v, err
 }
 
 // getCommandPath extracts the command path from kong's parent path
@@ -190,38 +272,111 @@ func getCommandPath(parent *kong.Path) []string {
 	return path
 }
 
-// extractValue extracts a Go value from a CUE value
-func extractValue(val cue.Value, isSlice bool) (any, error) {
-	// Handle lists/slices
-	if isSlice {
-		iter, err := val.List()
+// extractSlice converts a CUE list into a value for a slice flag.
+// Elements are joined with the flag's separator, escaping separators inside
+// elements, so Kong decodes each element with its usual mapper. Flags with
+// sep:"none" receive a []any instead. A single non-list value is passed
+// through as if it had been given on the command line.
+func extractSlice(val cue.Value, flag *kong.Flag, opts *schemaOptions) (any, error) {
+	iter, err := val.List()
+	if err != nil {
+		// Not a list, try as single value
+		native, err := scalarNative(val)
 		if err != nil {
-			// Not a list, try as single value
-			str, err := val.String()
-			if err != nil {
-				return nil, nil
-			}
-			return str, nil
+			return nil, nil
 		}
+		return fmt.Sprint(native), nil
+	}
 
-		var items []string
-		for iter.Next() {
-			str, err := iter.Value().String()
-			if err != nil {
-				continue
-			}
-			items = append(items, str)
+	elemMapping, hasMapping := opts.typeMapping(flag.Target.Type().Elem())
+	paths := isPathFlag(flag)
+
+	var items []string
+	var natives []any
+	for iter.Next() {
+		elem := iter.Value()
+		var native any
+		if hasMapping && elemMapping.Extract != nil {
+			native, err = elemMapping.Extract(elem)
+		} else {
+			native, err = scalarNative(elem)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("[%s]: %w", iter.Selector(), err)
+		}
+		if str, ok := native.(string); ok && paths {
+			native = rebasePath(str, elem)
 		}
+		items = append(items, fmt.Sprint(native))
+		natives = append(natives, native)
+	}
 
-		if len(items) == 0 {
-			return nil, nil
+	sep := flag.Tag.Sep
+	if len(items) == 0 || sep == -1 || !joinable(items) {
+		// Kong decodes []any by transcoding through JSON
+		if natives == nil {
+			natives = []any{}
 		}
-		if len(items) == 1 {
-			return items[0], nil
+		return natives, nil
+	}
+	return kong.JoinEscaped(items, sep), nil
+}
+
+// joinable reports whether items survive a kong.JoinEscaped/SplitEscaped
+// round trip. A trailing backslash would escape the following separator.
+func joinable(items []string) bool {
+	for _, item := range items {
+		if strings.HasSuffix(item, `\`) {
+			return false
 		}
-		return strings.Join(items, ","), nil
 	}
+	return true
+}
+
+// pathTypes are the Kong mapper names whose values are filesystem paths.
+var pathTypes = map[string]bool{
+	"path":         true,
+	"existingfile": true,
+	"existingdir":  true,
+	"filecontent":  true,
+}
+
+// pathGoTypes are Go types that Kong decodes from filesystem paths.
+var pathGoTypes = map[reflect.Type]bool{
+	reflect.TypeFor[*os.File]():                  true,
+	reflect.TypeFor[kong.FileContentFlag]():      true,
+	reflect.TypeFor[kong.NamedFileContentFlag](): true,
+}
+
+// isPathFlag reports whether a flag's values are filesystem paths.
+func isPathFlag(flag *kong.Flag) bool {
+	if pathTypes[flag.Tag.Type] {
+		return true
+	}
+	typ := flag.Target.Type()
+	if flag.IsSlice() {
+		typ = typ.Elem()
+	}
+	return pathGoTypes[typ]
+}
 
+// rebasePath resolves a relative path against the directory of the config
+// file that set it, so configs behave the same regardless of the working
+// directory. Absolute paths, ~ paths, "-" (stdin) and values without a
+// source file are returned unchanged.
+func rebasePath(path string, val cue.Value) string {
+	if path == "" || path == "-" || filepath.IsAbs(path) || strings.HasPrefix(path, "~") {
+		return path
+	}
+	file := val.Pos().Filename()
+	if file == "" || file == generatedSchemaFilename {
+		return path
+	}
+	return filepath.Join(filepath.Dir(file), path)
+}
+
+// extractValue extracts a Go scalar value from a CUE value
+func extractValue(val cue.Value) (any, error) {
 	// Handle booleans
 	if b, err := val.Bool(); err == nil {
 		if b {
@@ -231,11 +386,21 @@ func extractValue(val cue.Value, isSlice bool) (any, error) {
 	}
 
 	// Handle integers - return as int (not int64) for Kong compatibility
+	// Kong converts the int to the flag's own size, and the schema has
+	// already checked it fits
 	if i, err := val.Int64(); err == nil {
-		if i > 0 {
-			return int(i), nil
+		return int(i), nil
+	}
+	// Only uint64 flags go past the int64 range
+	if u, err := val.Uint64(); err == nil {
+		return u, nil
+	}
+
+	// Handle floats
+	if val.Kind() == cue.FloatKind {
+		if f, err := val.Float64(); err == nil {
+			return f, nil
 		}
-		return nil, nil // Don't return 0, let kong use default
 	}
 
 	// Handle strings
@@ -249,5 +414,61 @@ func extractValue(val cue.Value, isSlice bool) (any, error) {
 	return nil, nil
 }
 
+// extractMap converts a CUE struct into Kong's map flag syntax, joining
+// "key=value" entries with the flag's map separator and escaping
+// separators inside entries. Values are converted like slice elements.
+func extractMap(val cue.Value, flag *kong.Flag, opts *schemaOptions) (any, error) {
+	iter, err := val.Fields()
+	if err != nil {
+		return nil, fmt.Errorf("expected a map of key/value pairs: %w", err)
+	}
+
+	elemMapping, hasMapping := opts.typeMapping(flag.Target.Type().Elem())
+	var entries []string
+	for iter.Next() {
+		var native any
+		if hasMapping && elemMapping.Extract != nil {
+			native, err = elemMapping.Extract(iter.Value())
+		} else {
+			native, err = scalarNative(iter.Value())
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", iter.Selector(), err)
+		}
+		entries = append(entries, iter.Selector().Unquoted()+"="+fmt.Sprint(native))
+	}
+
+	if len(entries) == 0 {
+		return nil, nil
+	}
+	mapSep := flag.Tag.MapSep
+	if mapSep == -1 {
+		if len(entries) > 1 {
+			return nil, fmt.Errorf("map has %d entries but the flag has no map separator", len(entries))
+		}
+		return entries[0], nil
+	}
+	return kong.JoinEscaped(entries, mapSep), nil
+}
+
+// scalarNative converts a concrete CUE scalar to its Go equivalent.
+func scalarNative(val cue.Value) (any, error) {
+	switch val.Kind() {
+	case cue.StringKind:
+		return val.String()
+	case cue.IntKind:
+		if i, err := val.Int64(); err == nil {
+			return i, nil
+		}
+		return val.Uint64()
+	case cue.FloatKind:
+		return val.Float64()
+	case cue.BoolKind:
+		return val.Bool()
+	default:
+		return nil, fmt.Errorf("expected a string, number or bool, got %s", val.IncompleteKind())
+	}
+}
+
 // Ensure cueResolver implements kong.Resolver
 var _ kong.Resolver = (*cueResolver)(nil)
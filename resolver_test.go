@@ -1,10 +1,14 @@
 package kongcue_test
 
 import (
+	"math"
 	"os"
 	"path/filepath"
+	"reflect"
 	"testing"
+	"time"
 
+	"cuelang.org/go/cue"
 	"github.com/alecthomas/kong"
 	kongcue "github.com/brianm/kongcue"
 )
@@ -282,3 +286,468 @@ func TestNewResolver_EmptyConfig(t *testing.T) {
 		t.Fatalf("failed to parse with empty config: %v", err)
 	}
 }
+
+type mapCLI struct {
+	Labels map[string]string  `name:"labels"`
+	Limits map[string]int     `name:"limits"`
+	Ratios map[string]float64 `name:"ratios" mapsep:","`
+	Ports  map[int]string     `name:"ports"`
+	Config kongcue.Config     `name:"config"`
+
+	Agent struct {
+		Tags map[string]string `name:"tags"`
+	} `cmd:"agent"`
+}
+
+func TestResolve_MapFlags(t *testing.T) {
+	dir := t.TempDir()
+	configFile := filepath.Join(dir, "config.yaml")
+	if err := os.WriteFile(configFile, []byte(`
+labels:
+  env: prod
+  note: "a;b=c"
+limits:
+  cpu: 4
+  mem: 2048
+ratios:
+  read: 0.75
+  write: 1
+ports:
+  "80": http
+  "443": https
+agent:
+  tags:
+    team: infra
+`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	var cli mapCLI
+	parser, err := kong.New(&cli, kongcue.Options())
+	if err != nil {
+		t.Fatalf("failed to create parser: %v", err)
+	}
+	if _, err := parser.Parse([]string{"--config", configFile, "agent"}); err != nil {
+		t.Fatalf("failed to parse: %v", err)
+	}
+
+	if cli.Labels["env"] != "prod" || cli.Labels["note"] != "a;b=c" || len(cli.Labels) != 2 {
+		t.Errorf("unexpected labels: %v", cli.Labels)
+	}
+	if cli.Limits["cpu"] != 4 || cli.Limits["mem"] != 2048 {
+		t.Errorf("unexpected limits: %v", cli.Limits)
+	}
+	if cli.Ratios["read"] != 0.75 || cli.Ratios["write"] != 1 {
+		t.Errorf("unexpected ratios: %v", cli.Ratios)
+	}
+	if cli.Ports[80] != "http" || cli.Ports[443] != "https" {
+		t.Errorf("unexpected ports: %v", cli.Ports)
+	}
+	if cli.Agent.Tags["team"] != "infra" {
+		t.Errorf("unexpected nested command tags: %v", cli.Agent.Tags)
+	}
+}
+
+func TestResolve_MapFlagsRejectWrongTypes(t *testing.T) {
+	tests := []struct {
+		name   string
+		config string
+	}{
+		{"number for string value", "labels: {a: 1}"},
+		{"nested struct value", "labels: {a: {b: c}}"},
+		{"string for int value", "limits: {cpu: lots}"},
+		{"non-numeric key", "ports: {http: web}"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			configFile := filepath.Join(dir, "config.yaml")
+			if err := os.WriteFile(configFile, []byte(tt.config), 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			var cli mapCLI
+			parser, err := kong.New(&cli, kongcue.Options())
+			if err != nil {
+				t.Fatalf("failed to create parser: %v", err)
+			}
+			if _, err := parser.Parse([]string{"--config", configFile, "agent"}); err == nil {
+				t.Errorf("expected %q to be rejected", tt.config)
+			}
+		})
+	}
+}
+
+type sliceCLI struct {
+	Names    []string        `name:"names"`
+	Paths    []string        `name:"paths" sep:";"`
+	Raw      []string        `name:"raw" sep:"none"`
+	Counts   []int           `name:"counts"`
+	Weights  []float64       `name:"weights"`
+	Toggles  []bool          `name:"toggles"`
+	Waits    []time.Duration `name:"waits"`
+	Defaults []string        `name:"defaults" default:"x,y"`
+	Config   kongcue.Config  `name:"config"`
+}
+
+func TestResolve_SliceRoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	configFile := filepath.Join(dir, "config.yaml")
+	if err := os.WriteFile(configFile, []byte(`
+names: ["a,b", "c", 'd\e']
+paths: ["/x;y", "/z"]
+raw: ["1,2", "3;4"]
+counts: [1, -2, 30]
+weights: [0.5, 2, -1.25]
+toggles: [true, false, true]
+waits: ["1s", 1500000000]
+defaults: []
+`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	var cli sliceCLI
+	parser, err := kong.New(&cli, kongcue.Options())
+	if err != nil {
+		t.Fatalf("failed to create parser: %v", err)
+	}
+	if _, err := parser.Parse([]string{"--config", configFile}); err != nil {
+		t.Fatalf("failed to parse: %v", err)
+	}
+
+	if !reflect.DeepEqual(cli.Names, []string{"a,b", "c", `d\e`}) {
+		t.Errorf("unexpected names: %q", cli.Names)
+	}
+	if !reflect.DeepEqual(cli.Paths, []string{"/x;y", "/z"}) {
+		t.Errorf("unexpected paths: %q", cli.Paths)
+	}
+	if !reflect.DeepEqual(cli.Raw, []string{"1,2", "3;4"}) {
+		t.Errorf("unexpected raw: %q", cli.Raw)
+	}
+	if !reflect.DeepEqual(cli.Counts, []int{1, -2, 30}) {
+		t.Errorf("unexpected counts: %v", cli.Counts)
+	}
+	if !reflect.DeepEqual(cli.Weights, []float64{0.5, 2, -1.25}) {
+		t.Errorf("unexpected weights: %v", cli.Weights)
+	}
+	if !reflect.DeepEqual(cli.Toggles, []bool{true, false, true}) {
+		t.Errorf("unexpected toggles: %v", cli.Toggles)
+	}
+	if !reflect.DeepEqual(cli.Waits, []time.Duration{time.Second, 1500 * time.Millisecond}) {
+		t.Errorf("unexpected waits: %v", cli.Waits)
+	}
+	if len(cli.Defaults) != 0 {
+		t.Errorf("empty list should override default, got %q", cli.Defaults)
+	}
+}
+
+func TestResolve_FloatFlag(t *testing.T) {
+	dir := t.TempDir()
+	configFile := filepath.Join(dir, "config.yaml")
+	if err := os.WriteFile(configFile, []byte("ratio: 0.25"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	var cli struct {
+		Ratio  float64        `name:"ratio" default:"1"`
+		Config kongcue.Config `name:"config"`
+	}
+	parser, err := kong.New(&cli, kongcue.Options())
+	if err != nil {
+		t.Fatalf("failed to create parser: %v", err)
+	}
+	if _, err := parser.Parse([]string{"--config", configFile}); err != nil {
+		t.Fatalf("failed to parse: %v", err)
+	}
+	if cli.Ratio != 0.25 {
+		t.Errorf("expected ratio 0.25, got %v", cli.Ratio)
+	}
+}
+
+func TestResolve_PathsRelativeToConfigFile(t *testing.T) {
+	dir := t.TempDir()
+	configDir := filepath.Join(dir, "etc", "myapp")
+	if err := os.MkdirAll(filepath.Join(configDir, "certs"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(configDir, "certs", "ca.pem"), []byte("ca"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	configFile := filepath.Join(configDir, "config.yaml")
+	if err := os.WriteFile(configFile, []byte(`
+ca: certs/ca.pem
+log_dir: logs
+includes: ["a.conf", "/abs/b.conf"]
+name: certs/not-a-path
+home: ~/data
+`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	var cli struct {
+		Ca       string         `name:"ca" type:"existingfile"`
+		LogDir   string         `name:"log-dir" type:"path"`
+		Includes []string       `name:"includes" type:"path"`
+		Name     string         `name:"name"`
+		Home     string         `name:"home" type:"path"`
+		Config   kongcue.Config `name:"config"`
+	}
+	parser, err := kong.New(&cli, kongcue.Options())
+	if err != nil {
+		t.Fatalf("failed to create parser: %v", err)
+	}
+	if _, err := parser.Parse([]string{"--config", configFile}); err != nil {
+		t.Fatalf("failed to parse: %v", err)
+	}
+
+	if want := filepath.Join(configDir, "certs", "ca.pem"); cli.Ca != want {
+		t.Errorf("expected ca %q, got %q", want, cli.Ca)
+	}
+	if want := filepath.Join(configDir, "logs"); cli.LogDir != want {
+		t.Errorf("expected log_dir %q, got %q", want, cli.LogDir)
+	}
+	if want := []string{filepath.Join(configDir, "a.conf"), "/abs/b.conf"}; !reflect.DeepEqual(cli.Includes, want) {
+		t.Errorf("expected includes %q, got %q", want, cli.Includes)
+	}
+	if cli.Name != "certs/not-a-path" {
+		t.Errorf("non-path flag should be unchanged, got %q", cli.Name)
+	}
+	if home, _ := os.UserHomeDir(); cli.Home != filepath.Join(home, "data") {
+		t.Errorf("~ path should expand to home, got %q", cli.Home)
+	}
+}
+
+func TestResolve_CLIPathsRelativeToWorkingDir(t *testing.T) {
+	dir := t.TempDir()
+	configFile := filepath.Join(dir, "config.yaml")
+	if err := os.WriteFile(configFile, []byte("log_dir: logs"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	var cli struct {
+		LogDir string         `name:"log-dir" type:"path"`
+		Config kongcue.Config `name:"config"`
+	}
+	parser, err := kong.New(&cli, kongcue.Options())
+	if err != nil {
+		t.Fatalf("failed to create parser: %v", err)
+	}
+	if _, err := parser.Parse([]string{"--config", configFile, "--log-dir", "cli-logs"}); err != nil {
+		t.Fatalf("failed to parse: %v", err)
+	}
+
+	cwd, _ := os.Getwd()
+	if want := filepath.Join(cwd, "cli-logs"); cli.LogDir != want {
+		t.Errorf("expected CLI path %q, got %q", want, cli.LogDir)
+	}
+}
+
+type versionCmd struct {
+	Short bool `name:"short"`
+}
+
+func (versionCmd) SkipsConfig() bool { return true }
+
+// loadsConfigCmd implements ConfigCommand but needs the config.
+type loadsConfigCmd struct {
+	Port int `name:"port"`
+}
+
+func (loadsConfigCmd) SkipsConfig() bool { return false }
+
+// statusCmd isn't configured by the config, but doesn't skip it either.
+type statusCmd struct {
+	Verbose bool `name:"verbose"`
+}
+
+func (statusCmd) ExcludedFromSchema() bool { return true }
+
+type skipCLI struct {
+	Config  kongcue.Config     `default:"./config.yaml"`
+	Name    string             `name:"name"`
+	Version versionCmd         `cmd:""`
+	Run     struct{}           `cmd:""`
+	Serve   loadsConfigCmd     `cmd:""`
+	Status  statusCmd          `cmd:""`
+	Show    kongcue.ConfigShow `cmd:"" name:"config-show"`
+}
+
+func TestConfigCommand_SkipsBrokenConfig(t *testing.T) {
+	dir := t.TempDir()
+	t.Chdir(dir)
+	kongcue.WriteConfig(t, dir, "config.yaml", "name: 1\nbogus: true\n")
+
+	var cli skipCLI
+	parser, err := kong.New(&cli, kongcue.Options())
+	if err != nil {
+		t.Fatalf("failed to create parser: %v", err)
+	}
+	if _, err := parser.Parse([]string{"version"}); err != nil {
+		t.Errorf("version should run with a broken config: %v", err)
+	}
+	if _, err := parser.Parse([]string{"run"}); err == nil {
+		t.Error("expected the broken config to fail other commands")
+	}
+}
+
+func TestConfigCommand_LeftOutOfSchema(t *testing.T) {
+	var cli skipCLI
+	parser, err := kong.New(&cli, kongcue.Options())
+	if err != nil {
+		t.Fatalf("failed to create parser: %v", err)
+	}
+	config, _ := kongcue.LoadAndUnifyPaths([]string{})
+	schema, err := kongcue.GenerateSchema(config.Context(), parser.Model, nil)
+	if err != nil {
+		t.Fatalf("failed to generate schema: %v", err)
+	}
+	if !schema.LookupPath(cue.MakePath(cue.Str("run").Optional())).Exists() {
+		t.Error("run should be in the schema")
+	}
+	if schema.LookupPath(cue.MakePath(cue.Str("version").Optional())).Exists() {
+		t.Error("version should not be in the schema")
+	}
+	if !schema.LookupPath(cue.MakePath(cue.Str("serve").Optional())).Exists() {
+		t.Error("commands that don't skip the config should be in the schema")
+	}
+	if schema.LookupPath(cue.MakePath(cue.Str("config-show").Optional())).Exists() {
+		t.Error("config-show should not be in the schema")
+	}
+	if schema.LookupPath(cue.MakePath(cue.Str("status").Optional())).Exists() {
+		t.Error("commands excluded by ExcludedFromSchema should not be in the schema")
+	}
+}
+
+type loadedCmd struct {
+	Port int `name:"port"`
+
+	loaded *kongcue.Loaded
+	value  cue.Value
+}
+
+func (c *loadedCmd) Run(loaded *kongcue.Loaded, value cue.Value) error {
+	c.loaded, c.value = loaded, value
+	return nil
+}
+
+type loadedCLI struct {
+	Config kongcue.Config `default:"./config.yaml"`
+	Name   string         `name:"name"`
+	Agent  loadedCmd      `cmd:""`
+}
+
+func runLoaded(t *testing.T, args ...string) *loadedCmd {
+	t.Helper()
+	var cli loadedCLI
+	parser, err := kong.New(&cli, kongcue.Options())
+	if err != nil {
+		t.Fatalf("failed to create parser: %v", err)
+	}
+	ctx, err := parser.Parse(args)
+	if err != nil {
+		t.Fatalf("failed to parse: %v", err)
+	}
+	if err := ctx.Run(); err != nil {
+		t.Fatalf("failed to run: %v", err)
+	}
+	return &cli.Agent
+}
+
+func TestLoaded_NoConfig(t *testing.T) {
+	t.Chdir(t.TempDir())
+
+	cmd := runLoaded(t, "agent")
+	if cmd.loaded == nil || len(cmd.loaded.Files) != 0 || !cmd.loaded.Value.Exists() || !cmd.value.Exists() {
+		t.Errorf("expected an empty config to be bound, got %+v", cmd.loaded)
+	}
+}
+
+func TestLoaded_Files(t *testing.T) {
+	dir := t.TempDir()
+	t.Chdir(dir)
+	a := kongcue.WriteConfig(t, dir, "a.yaml", "name: alice\n")
+	b := kongcue.WriteConfig(t, dir, "b.yaml", "agent:\n  port: 8080\n")
+
+	cmd := runLoaded(t, "--config", a, "--config", b, "agent")
+	loaded := cmd.loaded
+	if !reflect.DeepEqual(loaded.Files, []string{a, b}) {
+		t.Errorf("unexpected files %v", loaded.Files)
+	}
+	if name, _ := loaded.FileValues[a].LookupPath(cue.ParsePath("name")).String(); name != "alice" {
+		t.Errorf("expected a.yaml's own value, got %v", loaded.FileValues[a])
+	}
+	if loaded.FileValues[a].LookupPath(cue.ParsePath("agent")).Exists() {
+		t.Error("a.yaml's value should not include b.yaml")
+	}
+	if file, line, ok := loaded.Source("agent.port"); !ok || file != b || line != 2 {
+		t.Errorf("expected agent.port to come from %s:2, got %s:%d", b, file, line)
+	}
+	if _, _, ok := loaded.Source("agent.ca_url"); ok {
+		t.Error("unset keys should have no source")
+	}
+	if !loaded.Schema.Exists() {
+		t.Error("expected the schema to be set")
+	}
+	if port, _ := cmd.value.LookupPath(cue.ParsePath("agent.port")).Int64(); port != 8080 {
+		t.Errorf("expected the merged value to be bound, got %v", cmd.value)
+	}
+}
+
+func TestResolve_NonPositiveIntegers(t *testing.T) {
+	tests := []struct {
+		config string
+		offset int16
+		level  int
+	}{
+		{"offset: -5\nlevel: -1\n", -5, -1},
+		{"offset: 0\nlevel: 0\n", 0, 0},
+		{"offset: -32768\n", -32768, 7},
+	}
+	for _, tt := range tests {
+		t.Run(tt.config, func(t *testing.T) {
+			dir := t.TempDir()
+			configFile := kongcue.WriteConfig(t, dir, "config.yaml", tt.config)
+
+			var cli struct {
+				Config kongcue.Config `name:"config"`
+				Offset int16          `name:"offset" default:"3"`
+				Level  int            `name:"level" default:"7"`
+			}
+			parser, err := kong.New(&cli, kongcue.Options())
+			if err != nil {
+				t.Fatalf("failed to create parser: %v", err)
+			}
+			if _, err := parser.Parse([]string{"--config", configFile}); err != nil {
+				t.Fatalf("failed to parse: %v", err)
+			}
+			if cli.Offset != tt.offset || cli.Level != tt.level {
+				t.Errorf("expected offset %d and level %d, got %d and %d", tt.offset, tt.level, cli.Offset, cli.Level)
+			}
+		})
+	}
+}
+
+func TestResolve_LargeUnsignedIntegers(t *testing.T) {
+	dir := t.TempDir()
+	configFile := kongcue.WriteConfig(t, dir, "config.yaml", "size: 18446744073709551615\nsizes: [18446744073709551615, 1]\n")
+
+	var cli struct {
+		Config kongcue.Config `name:"config"`
+		Size   uint64         `name:"size" default:"1"`
+		Sizes  []uint64       `name:"sizes"`
+	}
+	parser, err := kong.New(&cli, kongcue.Options())
+	if err != nil {
+		t.Fatalf("failed to create parser: %v", err)
+	}
+	if _, err := parser.Parse([]string{"--config", configFile}); err != nil {
+		t.Fatalf("failed to parse: %v", err)
+	}
+	if cli.Size != math.MaxUint64 {
+		t.Errorf("expected size %d, got %d", uint64(math.MaxUint64), cli.Size)
+	}
+	if len(cli.Sizes) != 2 || cli.Sizes[0] != math.MaxUint64 {
+		t.Errorf("unexpected sizes %v", cli.Sizes)
+	}
+}
@@ -0,0 +1,302 @@
+package kongcue
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/alecthomas/kong"
+)
+
+// sampleSyntax describes how a commented sample config is spelled in one
+// of the supported formats.
+type sampleSyntax struct {
+	comment string // line comment prefix
+	open    string // appended to a section's key to start its body
+	close   string // line closing a section's body, empty if none
+	indent  string // indentation for each nesting level
+}
+
+var (
+	yamlSyntax = sampleSyntax{comment: "# ", open: ":", indent: "  "}
+	cueSyntax  = sampleSyntax{comment: "// ", open: ": {", close: "}", indent: "\t"}
+)
+
+// Sample config formats supported by GenerateSampleConfig.
+var sampleFormats = []string{"yaml", "json", "cue"}
+
+// GenerateSampleConfig creates a starter config file from a Kong application
+// model in "yaml", "json" or "cue" format. Flags with defaults are filled in.
+// In YAML and CUE, help text becomes comments and flags without defaults are
+// commented out with a placeholder value; required flags are marked. JSON
+// has no comments, so it only contains the defaults. Versioned configs are
+// stamped with the latest config_version (see Migrate). opts may be nil.
+func GenerateSampleConfig(app *kong.Application, opts *SchemaOptions, format string) ([]byte, error) {
+	src, err := renderSample(buildConfigTree(app, opts.toInternal()), format)
+	if err != nil {
+		return nil, err
+	}
+	return stampSample(src, format, opts.toInternal())
+}
+
+// renderSample renders a sample config for a section in the given format.
+func renderSample(section *configSection, format string) ([]byte, error) {
+	var buf bytes.Buffer
+	switch format {
+	case "yaml", "yml":
+		writeSample(&buf, section, yamlSyntax, "", false)
+	case "cue":
+		writeSample(&buf, section, cueSyntax, "", false)
+	case "json":
+		src, err := json.MarshalIndent(sampleDefaults(section), "", "  ")
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(src)
+		buf.WriteByte('\n')
+	default:
+		return nil, fmt.Errorf("unknown sample format %q (expected one of %s)", format, strings.Join(sampleFormats, ", "))
+	}
+	return buf.Bytes(), nil
+}
+
+// writeSample writes a commented example config for a section.
+// Flags with defaults are filled in; the others are commented out with a
+// zero value so they can be uncommented and edited. When inBlock is set the
+// output will be commented out as a whole by the caller, so keys are not
+// commented individually.
+func writeSample(w io.Writer, section *configSection, syntax sampleSyntax, indent string, inBlock bool) {
+	first := true
+	separate := func() {
+		if !first {
+			fmt.Fprintln(w)
+		}
+		first = false
+	}
+
+	for _, flag := range section.flags {
+		if flag.Tag.Has(deprecatedTag) {
+			continue // Don't suggest deprecated keys in new configs
+		}
+		separate()
+		writeComments(w, syntax, indent, flagComments(flag))
+		key := kebabToSnake(flag.Name)
+		if val, ok := flagDefault(flag, section.opts); ok {
+			fmt.Fprintf(w, "%s%s: %s\n", indent, key, flowValue(val))
+		} else if inBlock {
+			fmt.Fprintf(w, "%s%s: %s\n", indent, key, flowValue(zeroValue(flag.Value, section.opts)))
+		} else {
+			fmt.Fprintf(w, "%s%s%s: %s\n", indent, syntax.comment, key, flowValue(zeroValue(flag.Value, section.opts)))
+		}
+	}
+
+	for _, child := range section.children {
+		separate()
+		name := child.path[len(child.path)-1]
+		if child.node.Help != "" {
+			writeComments(w, syntax, indent, []string{child.node.Help})
+		}
+		if inBlock || child.hasDefaults() {
+			fmt.Fprintf(w, "%s%s%s\n", indent, name, syntax.open)
+			writeSample(w, child, syntax, indent+syntax.indent, inBlock)
+			if syntax.close != "" {
+				fmt.Fprintf(w, "%s%s\n", indent, syntax.close)
+			}
+			continue
+		}
+		// Without any active keys the section would be empty (null in YAML),
+		// so comment it out as a whole
+		var buf strings.Builder
+		fmt.Fprintf(&buf, "%s%s\n", name, syntax.open)
+		writeSample(&buf, child, syntax, syntax.indent, true)
+		if syntax.close != "" {
+			fmt.Fprintf(&buf, "%s\n", syntax.close)
+		}
+		for line := range strings.SplitSeq(strings.TrimSuffix(buf.String(), "\n"), "\n") {
+			if line == "" {
+				fmt.Fprintln(w)
+				continue
+			}
+			fmt.Fprintf(w, "%s%s%s\n", indent, syntax.comment, line)
+		}
+	}
+}
+
+// writeComments writes each line as a comment.
+func writeComments(w io.Writer, syntax sampleSyntax, indent string, lines []string) {
+	for _, line := range lines {
+		fmt.Fprintf(w, "%s%s%s\n", indent, syntax.comment, line)
+	}
+}
+
+// sampleDefaults returns the defaults of a section and its descendants as
+// nested maps, omitting sections without any defaults.
+func sampleDefaults(section *configSection) map[string]any {
+	out := map[string]any{}
+	for _, flag := range section.flags {
+		if val, ok := flagDefault(flag, section.opts); ok && !flag.Tag.Has(deprecatedTag) {
+			out[kebabToSnake(flag.Name)] = val
+		}
+	}
+	for _, child := range section.children {
+		if child.hasDefaults() {
+			out[child.path[len(child.path)-1]] = sampleDefaults(child)
+		}
+	}
+	return out
+}
+
+// flagComments returns the comment lines describing a flag.
+func flagComments(flag *kong.Flag) []string {
+	var lines []string
+	if flag.Help != "" {
+		lines = append(lines, strings.Split(flag.Help, "\n")...)
+	}
+	if flag.Enum != "" {
+		lines = append(lines, "One of: "+strings.Join(flag.EnumSlice(), ", "))
+	}
+	if flag.Required {
+		lines = append(lines, "Required.")
+	}
+	return lines
+}
+
+// hasDefaults reports whether the section or any descendant has a flag
+// with a default value.
+func (s *configSection) hasDefaults() bool {
+	for _, flag := range s.flags {
+		if _, ok := flagDefault(flag, s.opts); ok {
+			return true
+		}
+	}
+	for _, child := range s.children {
+		if child.hasDefaults() {
+			return true
+		}
+	}
+	return false
+}
+
+// flagDefault returns a flag's default as a config value.
+// Returns false if the flag has no default.
+func flagDefault(flag *kong.Flag, opts *schemaOptions) (any, bool) {
+	if !flag.HasDefault {
+		return nil, false
+	}
+	return cliToConfigValue(flag.Value, flag.Default, opts), true
+}
+
+// cliToConfigValue converts a value in command line syntax to the value
+// that would be written in a config file for the flag.
+func cliToConfigValue(v *kong.Value, s string, opts *schemaOptions) any {
+	if v.IsSlice() {
+		items := []any{}
+		for _, item := range kong.SplitEscaped(s, v.Tag.Sep) {
+			items = append(items, scalarConfigValue(v.Target.Type().Elem(), item, opts))
+		}
+		return items
+	}
+	if v.IsMap() {
+		entries := map[string]any{}
+		for _, entry := range kong.SplitEscaped(s, v.Tag.MapSep) {
+			key, value, _ := strings.Cut(entry, "=")
+			entries[key] = scalarConfigValue(v.Target.Type().Elem(), value, opts)
+		}
+		return entries
+	}
+	if v.IsCounter() {
+		return scalarConfigValue(reflect.TypeFor[int](), s, opts)
+	}
+	return scalarConfigValue(v.Target.Type(), s, opts)
+}
+
+// scalarConfigValue converts a command line string to a native value for
+// types that are written unquoted in config files. Anything else,
+// including types with mappings, stays a string.
+func scalarConfigValue(typ reflect.Type, s string, opts *schemaOptions) any {
+	if _, ok := opts.typeMapping(typ); ok {
+		return s
+	}
+	switch typ.Kind() {
+	case reflect.Bool:
+		if b, err := strconv.ParseBool(s); err == nil {
+			return b
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if i, err := strconv.ParseInt(s, 0, 64); err == nil {
+			return i
+		}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		if u, err := strconv.ParseUint(s, 0, 64); err == nil {
+			return u
+		}
+	case reflect.Float32, reflect.Float64:
+		if f, err := strconv.ParseFloat(s, 64); err == nil {
+			return f
+		}
+	}
+	return s
+}
+
+// zeroValue returns the placeholder config value for a flag without default.
+func zeroValue(v *kong.Value, opts *schemaOptions) any {
+	switch {
+	case v.IsSlice():
+		return []any{}
+	case v.IsMap():
+		return map[string]any{}
+	case v.IsCounter():
+		return int64(0)
+	case v.IsBool():
+		return false
+	}
+	return scalarConfigValue(v.Target.Type(), zeroString(v.Target.Type(), opts), opts)
+}
+
+// zeroString returns the command line spelling of a type's zero value.
+func zeroString(typ reflect.Type, opts *schemaOptions) string {
+	if _, ok := opts.typeMapping(typ); ok {
+		return ""
+	}
+	switch typ.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return "0"
+	default:
+		return ""
+	}
+}
+
+// flowValue formats a native config value in YAML flow style, which is
+// also valid CUE.
+func flowValue(val any) string {
+	switch v := val.(type) {
+	case string:
+		return strconv.Quote(v)
+	case []any:
+		items := make([]string, len(v))
+		for i, item := range v {
+			items[i] = flowValue(item)
+		}
+		return "[" + strings.Join(items, ", ") + "]"
+	case map[string]any:
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		entries := make([]string, len(keys))
+		for i, k := range keys {
+			entries[i] = strconv.Quote(k) + ": " + flowValue(v[k])
+		}
+		return "{" + strings.Join(entries, ", ") + "}"
+	default:
+		return fmt.Sprint(v)
+	}
+}
@@ -0,0 +1,236 @@
+package kongcue_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alecthomas/kong"
+	kongcue "github.com/brianm/kongcue"
+)
+
+type sampleCLI struct {
+	Name    string            `help:"Who to greet" default:"world"`
+	Level   string            `help:"Log level" enum:"debug,info" default:"info"`
+	Token   string            `help:"API token"`
+	Timeout time.Duration     `help:"Request timeout" default:"30s"`
+	Tags    []string          `help:"Tags" default:"a,b"`
+	Labels  map[string]string `help:"Labels"`
+	Server  struct {
+		Port int `help:"Listen port" default:"8080"`
+		TLS  struct {
+			Cert string `help:"Certificate file"`
+		} `cmd:"" help:"TLS settings"`
+	} `cmd:"" help:"Run the server"`
+	Client struct {
+		URL string `name:"url" help:"Server URL"`
+	} `cmd:"" help:"Run the client"`
+	Config     kongcue.Config     `default:"./config.yaml"`
+	ConfigInit kongcue.ConfigInit `cmd:"config-init"`
+}
+
+func sampleParser(t *testing.T, cli *sampleCLI, options ...kong.Option) *kong.Kong {
+	t.Helper()
+	parser, err := kong.New(cli, append([]kong.Option{kongcue.Options()}, options...)...)
+	if err != nil {
+		t.Fatalf("failed to create parser: %v", err)
+	}
+	return parser
+}
+
+func TestGenerateSampleConfig_YAML(t *testing.T) {
+	var cli sampleCLI
+	parser := sampleParser(t, &cli)
+
+	src, err := kongcue.GenerateSampleConfig(parser.Model, nil, "yaml")
+	if err != nil {
+		t.Fatalf("failed to generate sample: %v", err)
+	}
+	output := string(src)
+
+	expected := []string{
+		"# Who to greet\nname: \"world\"\n",
+		"# Log level\n# One of: debug, info\nlevel: \"info\"\n",
+		"# API token\n# token: \"\"\n",
+		"timeout: \"30s\"\n",
+		"tags: [\"a\", \"b\"]\n",
+		"# labels: {}\n",
+		"# Run the server\nserver:\n  # Listen port\n  port: 8080\n",
+		"  # TLS settings\n  # tls:\n  #   # Certificate file\n  #   cert: \"\"\n",
+		"# Run the client\n# client:\n#   # Server URL\n#   url: \"\"\n",
+	}
+	for _, want := range expected {
+		if !strings.Contains(output, want) {
+			t.Errorf("expected sample to contain %q, got:\n%s", want, output)
+		}
+	}
+	if strings.Contains(output, "config") {
+		t.Errorf("config flag and config-init command should not appear, got:\n%s", output)
+	}
+}
+
+func TestGenerateSampleConfig_Required(t *testing.T) {
+	var cli struct {
+		Token string `help:"API token" required:""`
+	}
+	parser, err := kong.New(&cli)
+	if err != nil {
+		t.Fatalf("failed to create parser: %v", err)
+	}
+
+	src, err := kongcue.GenerateSampleConfig(parser.Model, nil, "yaml")
+	if err != nil {
+		t.Fatalf("failed to generate sample: %v", err)
+	}
+	if want := "# API token\n# Required.\n# token: \"\"\n"; string(src) != want {
+		t.Errorf("got:\n%s\nwant:\n%s", src, want)
+	}
+}
+
+func TestGenerateSampleConfig_Options(t *testing.T) {
+	var cli sampleCLI
+	parser := sampleParser(t, &cli)
+
+	plain, err := kongcue.GenerateSampleConfig(parser.Model, nil, "yaml")
+	if err != nil {
+		t.Fatalf("failed to generate sample: %v", err)
+	}
+	open, err := kongcue.GenerateSampleConfig(parser.Model, &kongcue.SchemaOptions{AllowAll: true}, "yaml")
+	if err != nil {
+		t.Fatalf("failed to generate sample: %v", err)
+	}
+	if string(open) != string(plain) {
+		t.Errorf("unknown fields shouldn't change the sample, got:\n%s\nwant:\n%s", open, plain)
+	}
+}
+
+func TestGenerateSampleConfig_RoundTrips(t *testing.T) {
+	for _, format := range []string{"yaml", "json", "cue"} {
+		t.Run(format, func(t *testing.T) {
+			var cli sampleCLI
+			parser := sampleParser(t, &cli)
+
+			src, err := kongcue.GenerateSampleConfig(parser.Model, nil, format)
+			if err != nil {
+				t.Fatalf("failed to generate sample: %v", err)
+			}
+
+			configFile := filepath.Join(t.TempDir(), "config."+format)
+			if err := os.WriteFile(configFile, src, 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			// The sample must be accepted by the same schema used at runtime
+			_, err = parser.Parse([]string{"--config", configFile, "server", "tls"})
+			if err != nil {
+				t.Fatalf("sample config should validate: %v\n%s", err, src)
+			}
+			if cli.Name != "world" || cli.Server.Port != 8080 {
+				t.Errorf("unexpected values from sample: name=%q port=%d", cli.Name, cli.Server.Port)
+			}
+		})
+	}
+}
+
+func TestGenerateSampleConfig_JSON(t *testing.T) {
+	var cli sampleCLI
+	parser := sampleParser(t, &cli)
+
+	src, err := kongcue.GenerateSampleConfig(parser.Model, nil, "json")
+	if err != nil {
+		t.Fatalf("failed to generate sample: %v", err)
+	}
+	var doc map[string]any
+	if err := json.Unmarshal(src, &doc); err != nil {
+		t.Fatalf("invalid JSON: %v\n%s", err, src)
+	}
+	if doc["name"] != "world" {
+		t.Errorf("expected default name, got %v", doc["name"])
+	}
+	if _, ok := doc["token"]; ok {
+		t.Error("flags without defaults should be omitted from JSON")
+	}
+	if _, ok := doc["client"]; ok {
+		t.Error("sections without defaults should be omitted from JSON")
+	}
+}
+
+func TestGenerateSampleConfig_UnknownFormat(t *testing.T) {
+	var cli sampleCLI
+	parser := sampleParser(t, &cli)
+
+	if _, err := kongcue.GenerateSampleConfig(parser.Model, nil, "toml"); err == nil {
+		t.Error("expected error for unknown format")
+	}
+}
+
+func TestConfigInit_WritesFile(t *testing.T) {
+	dir := t.TempDir()
+	configFile := filepath.Join(dir, "config.cue")
+
+	// A broken default config must not prevent config-init from running
+	t.Chdir(dir)
+	if err := os.WriteFile("config.yaml", []byte("bogus: ["), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	var cli sampleCLI
+	exited := -1
+	parser := sampleParser(t, &cli, kong.Exit(func(code int) { exited = code }))
+	if _, err := parser.Parse([]string{"config-init", "--output", configFile}); err != nil {
+		t.Fatalf("failed to parse: %v", err)
+	}
+	if exited != 0 {
+		t.Errorf("expected app.Exit(0), got %d", exited)
+	}
+
+	data, err := os.ReadFile(configFile)
+	if err != nil {
+		t.Fatalf("expected config file to be written: %v", err)
+	}
+	if !strings.Contains(string(data), "// Who to greet\nname: \"world\"") {
+		t.Errorf("expected CUE sample from .cue extension, got:\n%s", data)
+	}
+}
+
+func TestConfigInit_RefusesOverwrite(t *testing.T) {
+	configFile := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(configFile, []byte("name: mine\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	var cli sampleCLI
+	parser := sampleParser(t, &cli, kong.Exit(func(int) {}))
+	_, err := parser.Parse([]string{"config-init", "--output", configFile})
+	if err == nil || !strings.Contains(err.Error(), "--force") {
+		t.Fatalf("expected refusal to overwrite, got: %v", err)
+	}
+	if data, _ := os.ReadFile(configFile); string(data) != "name: mine\n" {
+		t.Errorf("existing file should be untouched, got:\n%s", data)
+	}
+
+	if _, err := parser.Parse([]string{"config-init", "--output", configFile, "--force"}); err != nil {
+		t.Fatalf("--force should overwrite: %v", err)
+	}
+	if data, _ := os.ReadFile(configFile); !strings.Contains(string(data), `name: "world"`) {
+		t.Errorf("expected file to be overwritten, got:\n%s", data)
+	}
+}
+
+func TestConfigInit_Stdout(t *testing.T) {
+	var cli sampleCLI
+	var buf bytes.Buffer
+	cli.ConfigInit.Output = &buf
+
+	parser := sampleParser(t, &cli)
+	if _, err := parser.Parse([]string{"config-init", "--format", "json"}); err != nil {
+		t.Fatalf("failed to parse: %v", err)
+	}
+	if !strings.Contains(buf.String(), `"name": "world"`) {
+		t.Errorf("expected JSON sample on stdout, got:\n%s", buf.String())
+	}
+}
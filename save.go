@@ -0,0 +1,84 @@
+package kongcue
+
+import (
+	"fmt"
+
+	"github.com/alecthomas/kong"
+)
+
+// SaveFlags writes the flags explicitly set on the command line for the
+// selected command into a config file, using the same key names the config
+// is loaded with. Existing files are merged: keys that are set are
+// replaced, and other keys and comments are kept. The format follows the
+// file's extension: .cue, .json, or YAML otherwise.
+//
+// Returns the number of settings saved.
+func SaveFlags(ctx *kong.Context, path string) (int, error) {
+	opts := schemaOptionsFrom(ctx).toInternal()
+	var edits []configEdit
+	for _, trace := range ctx.Path {
+		if trace.App == nil && trace.Command == nil && trace.Argument == nil {
+			continue
+		}
+		cmdPath := getCommandPath(trace)
+		for _, flag := range trace.Node().Flags {
+			if !isConfigFlag(flag) || !setOnCommandLine(ctx, flag) {
+				continue
+			}
+			edits = append(edits, configEdit{
+				path:  append(append([]string{}, cmdPath...), kebabToSnake(flag.Name)),
+				value: goToConfigValue(ctx.FlagValue(flag), flag.Format, opts),
+			})
+		}
+	}
+
+	if err := editConfigFile(path, edits, opts); err != nil {
+		return 0, err
+	}
+	return len(edits), nil
+}
+
+// setOnCommandLine reports whether a flag was given on the command line,
+// as opposed to being resolved from config or left at its default.
+func setOnCommandLine(ctx *kong.Context, flag *kong.Flag) bool {
+	for _, trace := range ctx.Path {
+		if trace.Flag == flag && !trace.Resolved {
+			return true
+		}
+	}
+	return false
+}
+
+// SaveConfig is a flag that saves the flags given on the command line to a
+// config file and exits, so a command line tuned by hand can be kept.
+// Existing files are updated in place, keeping their other keys and
+// comments. See SaveFlags.
+//
+// Usage:
+//
+//	type cli struct {
+//	    Config     kongcue.Config     `default:"~/.myapp.yaml"`
+//	    SaveConfig kongcue.SaveConfig `help:"Save the given flags to this config file and exit." placeholder:"FILE"`
+//	    Agent      agentCmd           `cmd:""`
+//	}
+//
+// Running `./myapp agent --port 8443 --save-config ~/.myapp.yaml` adds
+// `agent: port: 8443` to the config file.
+type SaveConfig string
+
+// BeforeApply saves the flags. It runs before required flags are checked,
+// so a partial command line can be saved.
+func (s SaveConfig) BeforeApply(app *kong.Kong, ctx *kong.Context, trace *kong.Path) error {
+	path, _ := ctx.FlagValue(trace.Flag).(SaveConfig)
+	if path == "" {
+		return nil
+	}
+	file := kong.ExpandPath(string(path))
+	n, err := SaveFlags(ctx, file)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(app.Stdout, "Saved %d setting(s) to %s\n", n, file)
+	app.Exit(0)
+	return nil
+}
@@ -0,0 +1,240 @@
+package kongcue_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alecthomas/kong"
+	kongcue "github.com/brianm/kongcue"
+)
+
+type saveCLI struct {
+	Verbose    int                `type:"counter"`
+	Name       string             `default:"world"`
+	SaveConfig kongcue.SaveConfig `name:"save-config"`
+	Config     kongcue.Config     `default:"./config.yaml"`
+	Agent      struct {
+		CaURL   string        `name:"ca-url" required:""`
+		Port    int           `default:"8080"`
+		Hosts   []string      `name:"hosts"`
+		Timeout time.Duration `name:"timeout"`
+	} `cmd:""`
+}
+
+// saveFlags parses args and saves the flags given on the command line to
+// path, returning the file's new content.
+func saveFlags(t *testing.T, path string, args ...string) string {
+	t.Helper()
+	var cli saveCLI
+	var stdout bytes.Buffer
+	exited := -1
+	parser, err := kong.New(&cli, kongcue.Options(),
+		kong.Writers(&stdout, &stdout),
+		kong.Exit(func(code int) {
+			exited = code
+			panic("exit")
+		}))
+	if err != nil {
+		t.Fatalf("failed to create parser: %v", err)
+	}
+
+	func() {
+		defer func() { recover() }()
+		parser.Parse(append(args, "--save-config", path))
+	}()
+	if exited != 0 {
+		t.Fatalf("expected exit status 0, got %d (output: %s)", exited, stdout.String())
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("failed to read saved config: %v", err)
+	}
+	return string(data)
+}
+
+func TestSaveConfig_NewYAML(t *testing.T) {
+	dir := t.TempDir()
+	t.Chdir(dir)
+	path := filepath.Join(dir, "saved.yaml")
+
+	got := saveFlags(t, path, "--verbose", "--verbose", "agent", "--hosts", "a,b", "--timeout", "90s")
+
+	// Defaults and flags that weren't given are not saved
+	expected := "" +
+		"verbose: 2\n" +
+		"agent:\n" +
+		"  hosts:\n" +
+		"    - a\n" +
+		"    - b\n" +
+		"  timeout: 1m30s\n"
+	if got != expected {
+		t.Errorf("got:\n%s\nwant:\n%s", got, expected)
+	}
+}
+
+func TestSaveConfig_MergesYAML(t *testing.T) {
+	dir := t.TempDir()
+	t.Chdir(dir)
+	path := kongcue.WriteConfig(t, dir, "config.yaml", ""+
+		"# Team settings\n"+
+		"name: team\n"+
+		"agent:\n"+
+		"  ca_url: https://ca.example.com # production CA\n"+
+		"  port: 9000\n")
+
+	got := saveFlags(t, path, "agent", "--ca-url", "https://ca.test", "--hosts", "x")
+
+	expected := "" +
+		"# Team settings\n" +
+		"name: team\n" +
+		"agent:\n" +
+		"  ca_url: https://ca.test # production CA\n" +
+		"  port: 9000\n" +
+		"  hosts:\n" +
+		"    - x\n"
+	if got != expected {
+		t.Errorf("got:\n%s\nwant:\n%s", got, expected)
+	}
+}
+
+func TestSaveConfig_MergesCUE(t *testing.T) {
+	dir := t.TempDir()
+	t.Chdir(dir)
+	path := kongcue.WriteConfig(t, dir, "config.cue", ""+
+		"// Team settings\n"+
+		"name: \"team\"\n"+
+		"agent: {\n"+
+		"\t// The CA\n"+
+		"\tca_url: \"https://ca.example.com\"\n"+
+		"}\n")
+
+	got := saveFlags(t, path, "--name", "solo", "agent", "--port", "8443")
+
+	expected := "" +
+		"// Team settings\n" +
+		"name: \"solo\"\n" +
+		"agent: {\n" +
+		"\t// The CA\n" +
+		"\tca_url: \"https://ca.example.com\"\n" +
+		"\tport:   8443\n" +
+		"}\n"
+	if got != expected {
+		t.Errorf("got:\n%s\nwant:\n%s", got, expected)
+	}
+}
+
+func TestSaveConfig_JSON(t *testing.T) {
+	dir := t.TempDir()
+	t.Chdir(dir)
+	path := kongcue.WriteConfig(t, dir, "config.json", `{"name": "team", "extra": true}`)
+
+	got := saveFlags(t, path, "agent", "--port", "1")
+
+	var doc map[string]any
+	if err := json.Unmarshal([]byte(got), &doc); err != nil {
+		t.Fatalf("invalid JSON: %v\n%s", err, got)
+	}
+	if doc["name"] != "team" || doc["extra"] != true {
+		t.Errorf("existing keys should be kept, got:\n%s", got)
+	}
+	if port := lookup(doc, "agent", "port"); port != float64(1) {
+		t.Errorf("expected agent.port 1, got %v", port)
+	}
+}
+
+func TestSaveConfig_RoundTrips(t *testing.T) {
+	dir := t.TempDir()
+	t.Chdir(dir)
+	path := filepath.Join(dir, "config.yaml")
+
+	saveFlags(t, path, "agent", "--ca-url", "https://ca.test", "--hosts", "a,b")
+
+	// The saved file must load back to the same values
+	var cli saveCLI
+	parser, err := kong.New(&cli, kongcue.Options())
+	if err != nil {
+		t.Fatalf("failed to create parser: %v", err)
+	}
+	if _, err := parser.Parse([]string{"agent"}); err != nil {
+		t.Fatalf("failed to parse with saved config: %v", err)
+	}
+	if cli.Agent.CaURL != "https://ca.test" || strings.Join(cli.Agent.Hosts, ",") != "a,b" {
+		t.Errorf("unexpected values from saved config: %+v", cli.Agent)
+	}
+}
+
+type typedSaveCLI struct {
+	SaveConfig kongcue.SaveConfig `name:"save-config"`
+	Config     kongcue.Config     `default:"./config.yaml"`
+	Timeout    time.Duration      `name:"timeout"`
+	Since      time.Time          `name:"since"`
+	Day        time.Time          `name:"day" format:"2006-01-02"`
+	Endpoint   *url.URL           `name:"endpoint"`
+	Bind       net.IP             `name:"bind"`
+	Peers      []net.IP           `name:"peers"`
+}
+
+func TestSaveConfig_RoundTripsTypeMappings(t *testing.T) {
+	dir := t.TempDir()
+	t.Chdir(dir)
+	path := filepath.Join(dir, "config.yaml")
+	args := []string{
+		"--timeout", "1m30s",
+		"--since", "2024-03-01T12:30:00+02:00",
+		"--day", "2024-03-01",
+		"--endpoint", "https://ca.test/v1?x=1",
+		"--bind", "10.0.0.1",
+		"--peers", "10.0.0.2,::1",
+	}
+
+	var saved typedSaveCLI
+	var stdout bytes.Buffer
+	parser, err := kong.New(&saved, kongcue.Options(),
+		kong.Writers(&stdout, &stdout),
+		kong.Exit(func(int) { panic("exit") }))
+	if err != nil {
+		t.Fatalf("failed to create parser: %v", err)
+	}
+	func() {
+		defer func() { recover() }()
+		parser.Parse(append(args, "--save-config", path))
+	}()
+
+	var loaded typedSaveCLI
+	parser, err = kong.New(&loaded, kongcue.Options())
+	if err != nil {
+		t.Fatalf("failed to create parser: %v", err)
+	}
+	if _, err := parser.Parse(nil); err != nil {
+		data, _ := os.ReadFile(path)
+		t.Fatalf("failed to parse with saved config: %v\n%s", err, data)
+	}
+
+	// Compare against the command line alone
+	var want typedSaveCLI
+	parser, err = kong.New(&want, kongcue.Options())
+	if err != nil {
+		t.Fatalf("failed to create parser: %v", err)
+	}
+	if _, err := parser.Parse(append(args, "--config", filepath.Join(dir, "missing.yaml"))); err != nil {
+		t.Fatalf("failed to parse: %v", err)
+	}
+	loaded.Config, want.Config = nil, nil
+	if !loaded.Since.Equal(want.Since) {
+		t.Errorf("since: got %v, want %v", loaded.Since, want.Since)
+	}
+	loaded.Since, want.Since = time.Time{}, time.Time{}
+	if !reflect.DeepEqual(loaded, want) {
+		data, _ := os.ReadFile(path)
+		t.Errorf("saved config loads differently:\ngot  %+v\nwant %+v\n%s", loaded, want, data)
+	}
+}
@@ -4,11 +4,14 @@ import (
 	"fmt"
 	"reflect"
 	"sort"
+	"strconv"
 	"strings"
+	"sync"
 
 	"cuelang.org/go/cue"
 	"cuelang.org/go/cue/ast"
 	"cuelang.org/go/cue/format"
+	"cuelang.org/go/cue/parser"
 	"cuelang.org/go/cue/token"
 	"github.com/alecthomas/kong"
 )
@@ -18,13 +21,36 @@ type schemaOptions struct {
 	allowUnknownPaths []string // Paths where unknown fields are allowed (empty = nowhere, nil with allowAll = everywhere)
 	allowAll          bool     // Allow unknown fields everywhere (backwards compat for no-arg call)
 	permissiveTypes   bool     // Use _ for all types (for unknown field checking only)
+
+	sections   map[string]reflect.Type      // Sections added with WithSection
+	types      map[reflect.Type]TypeMapping // Type mappings added with WithType
+	migrations map[int]migration            // Migrations added with WithMigration
 }
 
-// SchemaOptions holds configuration for schema generation.
-// Exported so it can be received via Kong's dependency injection in hooks.
+// SchemaOptions holds configuration for schema generation and config
+// loading. Exported so it can be received via Kong's dependency injection
+// in hooks. It's set up by Options and the other kongcue options; binding
+// one directly with kong.Bind replaces theirs.
 type SchemaOptions struct {
 	AllowUnknownPaths []string
 	AllowAll          bool
+
+	// OnWarning receives warnings about a loaded config, such as deprecated
+	// keys. If nil, warnings are printed to the application's stderr.
+	OnWarning func(Warning)
+
+	// StrictWarnings makes warnings errors, for strict CI runs.
+	StrictWarnings bool
+
+	// SelectedCommandOnly limits validation when parsing to the global
+	// flags and the selected command's section, and those of its parents,
+	// so a broken section for another command doesn't stop this one from
+	// running. Unknown keys at those levels are still reported.
+	SelectedCommandOnly bool
+
+	sections   map[string]reflect.Type      // added with WithSection
+	types      map[reflect.Type]TypeMapping // added with WithType
+	migrations map[int]migration            // added with WithMigration
 }
 
 // toInternal converts exported SchemaOptions to internal schemaOptions.
@@ -35,21 +61,73 @@ func (o *SchemaOptions) toInternal() *schemaOptions {
 	return &schemaOptions{
 		allowUnknownPaths: o.AllowUnknownPaths,
 		allowAll:          o.AllowAll,
+		sections:          o.sections,
+		types:             o.types,
+		migrations:        o.migrations,
 	}
 }
 
-// defaultSchemaOptions is the default binding used when AllowUnknownFields is not called.
-var defaultSchemaOptions = &SchemaOptions{}
+// schemaOptionsFrom returns the SchemaOptions bound in a Kong context, or
+// nil if there are none.
+func schemaOptionsFrom(ctx *kong.Context) *SchemaOptions {
+	var opts *SchemaOptions
+	_, _ = ctx.Call(func(o *SchemaOptions) { opts = o })
+	return opts
+}
+
+// generatedSchemaFilename is the filename attached to the compiled schema,
+// used to tell schema positions apart from config file positions.
+const generatedSchemaFilename = "generated-schema"
+
+var (
+	appOptionsMu sync.Mutex
+	appOptions   = map[*kong.Kong]*SchemaOptions{} // options of applications being built
+)
+
+// schemaOption returns a Kong option that applies fn to the SchemaOptions
+// bound for the application, so that kongcue's options add up instead of
+// replacing each other's binding.
+func schemaOption(fn func(*SchemaOptions) error) kong.Option {
+	return kong.OptionFunc(func(k *kong.Kong) error {
+		appOptionsMu.Lock()
+		opts, ok := appOptions[k]
+		if !ok {
+			opts = &SchemaOptions{}
+			appOptions[k] = opts
+		}
+		err := fn(opts)
+		appOptionsMu.Unlock()
+		if err != nil {
+			return err
+		}
+
+		if !ok {
+			// Every option has been applied once the model is built
+			forget := kong.PostBuild(func(k *kong.Kong) error {
+				appOptionsMu.Lock()
+				defer appOptionsMu.Unlock()
+				delete(appOptions, k)
+				return nil
+			})
+			if err := forget.Apply(k); err != nil {
+				return err
+			}
+		}
+		// Bind options so hooks can receive them via DI
+		return kong.Bind(opts).Apply(k)
+	})
+}
 
 // Options returns a Kong option that sets up kongcue's schema validation.
 // This must be included when using kongcue.Config or kongcue.ConfigDoc.
-// If you want to allow unknown fields, use AllowUnknownFields() instead.
+// The other options, such as AllowUnknownFields, include it, and can be
+// combined.
 //
 // Usage:
 //
 //	kong.Parse(&cli, kongcue.Options())
 func Options() kong.Option {
-	return kong.Bind(defaultSchemaOptions)
+	return schemaOption(func(*SchemaOptions) error { return nil })
 }
 
 // AllowUnknownFields returns a Kong option that allows unknown config keys.
@@ -63,14 +141,43 @@ func Options() kong.Option {
 //	kong.Parse(&cli, kongcue.AllowUnknownFields())                    // allow everywhere
 //	kong.Parse(&cli, kongcue.AllowUnknownFields("extra", "legacy"))   // allow at specific paths
 func AllowUnknownFields(paths ...string) kong.Option {
-	opts := &SchemaOptions{}
-	if len(paths) == 0 {
-		opts.AllowAll = true
-	} else {
-		opts.AllowUnknownPaths = paths
-	}
-	// Bind options so hooks can receive them via DI
-	return kong.Bind(opts)
+	return schemaOption(func(opts *SchemaOptions) error {
+		if len(paths) == 0 {
+			opts.AllowAll = true
+		} else {
+			opts.AllowUnknownPaths = append(opts.AllowUnknownPaths, paths...)
+		}
+		return nil
+	})
+}
+
+// OnWarning returns a Kong option that passes warnings about the loaded
+// config, such as deprecated keys, to fn instead of printing them. See
+// SchemaOptions.OnWarning.
+func OnWarning(fn func(Warning)) kong.Option {
+	return schemaOption(func(opts *SchemaOptions) error {
+		opts.OnWarning = fn
+		return nil
+	})
+}
+
+// StrictWarnings returns a Kong option that makes warnings about the loaded
+// config errors, for strict CI runs. See SchemaOptions.StrictWarnings.
+func StrictWarnings() kong.Option {
+	return schemaOption(func(opts *SchemaOptions) error {
+		opts.StrictWarnings = true
+		return nil
+	})
+}
+
+// SelectedCommandOnly returns a Kong option that only validates the
+// sections of the selected command and its parents when parsing. See
+// SchemaOptions.SelectedCommandOnly.
+func SelectedCommandOnly() kong.Option {
+	return schemaOption(func(opts *SchemaOptions) error {
+		opts.SelectedCommandOnly = true
+		return nil
+	})
 }
 
 // shouldAllowUnknown checks if unknown fields should be allowed at the given path.
@@ -103,6 +210,14 @@ func GenerateSchema(ctx *cue.Context, app *kong.Application, opts *schemaOptions
 		opts = &schemaOptions{}
 	}
 
+	// Reject malformed cue:"..." tags before they are silently dropped
+	if err := checkConstraintTags(app.Node); err != nil {
+		return cue.Value{}, err
+	}
+	if err := checkSections(app, opts); err != nil {
+		return cue.Value{}, err
+	}
+
 	// Generate schema with named definitions
 	file := GenerateSchemaFile(app, opts)
 
@@ -113,7 +228,7 @@ func GenerateSchema(ctx *cue.Context, app *kong.Application, opts *schemaOptions
 	}
 
 	// Compile to CUE value
-	schemaVal := ctx.CompileBytes(src, cue.Filename("generated-schema"))
+	schemaVal := ctx.CompileBytes(src, cue.Filename(generatedSchemaFilename))
 	if err := schemaVal.Err(); err != nil {
 		return cue.Value{}, fmt.Errorf("failed to compile schema: %w", err)
 	}
@@ -136,12 +251,12 @@ func ValidateConfig(schema cue.Value, config cue.Value) error {
 	unified := schema.Unify(config)
 
 	if err := unified.Err(); err != nil {
-		return formatValidationError(err)
+		return newValidationError(nil, nil, err)
 	}
 
 	// Validate to catch additional constraint violations
 	if err := unified.Validate(); err != nil {
-		return formatValidationError(err)
+		return newValidationError(nil, nil, err)
 	}
 
 	return nil
@@ -179,10 +294,15 @@ func (opts *schemaOptions) getAllowedFieldAtPath(allowed, path string) string {
 
 // valueToType converts a Kong value to a CUE type expression.
 // Types are made coercible by allowing string alternatives.
-func valueToType(v *kong.Value) ast.Expr {
+func valueToType(v *kong.Value, opts *schemaOptions) ast.Expr {
+	// Handle types with mappings (time.Duration, net.IP, ...)
+	if m, ok := opts.typeMapping(v.Target.Type()); ok {
+		return m.schema()
+	}
+
 	// Handle slices
 	if v.IsSlice() {
-		elemType := sliceElemType(v.Target)
+		elemType := sliceElemType(v.Target, opts)
 		return &ast.ListLit{
 			Elts: []ast.Expr{&ast.Ellipsis{Type: elemType}},
 		}
@@ -190,8 +310,7 @@ func valueToType(v *kong.Value) ast.Expr {
 
 	// Handle maps
 	if v.IsMap() {
-		// Open struct pattern: {[string]: _}
-		return ast.NewStruct()
+		return mapType(v.Target.Type(), opts)
 	}
 
 	// Handle counters (like -v -v -v for verbosity)
@@ -208,16 +327,33 @@ func valueToType(v *kong.Value) ast.Expr {
 	return kindToType(v.Target.Kind())
 }
 
+// numericKindTypes maps Go numeric kinds to CUE's predeclared bounded types,
+// so out-of-range config values are rejected during validation rather than
+// by Kong's mapper. Go's int and uint are platform sized, so they map to the
+// unbounded int and non-negative uint.
+var numericKindTypes = map[reflect.Kind]string{
+	reflect.Int:     "int",
+	reflect.Int8:    "int8",
+	reflect.Int16:   "int16",
+	reflect.Int32:   "int32",
+	reflect.Int64:   "int64",
+	reflect.Uint:    "uint",
+	reflect.Uint8:   "uint8",
+	reflect.Uint16:  "uint16",
+	reflect.Uint32:  "uint32",
+	reflect.Uint64:  "uint64",
+	reflect.Float32: "float32",
+	reflect.Float64: "float64",
+}
+
 // kindToType converts a reflect.Kind to a CUE type expression.
 func kindToType(k reflect.Kind) ast.Expr {
+	if name, ok := numericKindTypes[k]; ok {
+		return ast.NewIdent(name)
+	}
 	switch k {
 	case reflect.String:
 		return ast.NewIdent("string")
-	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
-		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
-		return ast.NewIdent("int")
-	case reflect.Float32, reflect.Float64:
-		return ast.NewIdent("number")
 	case reflect.Bool:
 		return ast.NewIdent("bool")
 	default:
@@ -226,12 +362,95 @@ func kindToType(k reflect.Kind) ast.Expr {
 }
 
 // sliceElemType returns the CUE type for slice elements.
-func sliceElemType(v reflect.Value) ast.Expr {
+func sliceElemType(v reflect.Value, opts *schemaOptions) ast.Expr {
 	if v.Kind() != reflect.Slice {
 		return ast.NewIdent("_")
 	}
-	elemKind := v.Type().Elem().Kind()
-	return kindToType(elemKind)
+	return goTypeToType(v.Type().Elem(), opts)
+}
+
+// mapType returns a CUE pattern constraint for a map type.
+// map[string]int -> {[string]: int}
+func mapType(t reflect.Type, opts *schemaOptions) ast.Expr {
+	return &ast.StructLit{Elts: []ast.Decl{
+		&ast.Field{
+			Label: &ast.ListLit{Elts: []ast.Expr{mapKeyType(t.Key())}},
+			Value: goTypeToType(t.Elem(), opts),
+		},
+	}}
+}
+
+// mapKeyType returns the CUE label constraint for a map key type.
+func mapKeyType(t reflect.Type) ast.Expr {
+	if pattern := mapKeyPattern(t); pattern != "" {
+		return &ast.UnaryExpr{Op: token.MAT, X: ast.NewString(pattern)}
+	}
+	return ast.NewIdent("string")
+}
+
+// mapKeyPattern returns the regexp config keys must match for a map key
+// type, or "" if any key is allowed. Config keys are always strings, so
+// numeric keys are matched by pattern.
+func mapKeyPattern(t reflect.Type) string {
+	switch t.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return `^-?[0-9]+$`
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return `^[0-9]+$`
+	default:
+		return ""
+	}
+}
+
+// constraintTag is the struct tag holding an extra CUE constraint for a flag,
+// e.g. `cue:">=1 & <=65535"` or `cue:"=~\"^https://\""`.
+const constraintTag = "cue"
+
+// flagType returns the CUE type expression for a flag, conjoined with any
+// constraint from its cue:"..." tag.
+func flagType(flag *kong.Flag, opts *schemaOptions) ast.Expr {
+	if opts.permissiveTypes {
+		return ast.NewIdent("_")
+	}
+	base := valueToType(flag.Value, opts)
+	constraint, err := parseConstraint(flag)
+	if err != nil || constraint == nil {
+		// Malformed tags are reported by checkConstraintTags
+		return base
+	}
+	return ast.NewBinExpr(token.AND, base, constraint)
+}
+
+// parseConstraint parses the cue:"..." tag of a flag as a CUE expression.
+// Returns nil if the flag has no constraint.
+func parseConstraint(flag *kong.Flag) (ast.Expr, error) {
+	if flag.Tag == nil {
+		return nil, nil
+	}
+	src := strings.TrimSpace(flag.Tag.Get(constraintTag))
+	if src == "" {
+		return nil, nil
+	}
+	expr, err := parser.ParseExpr("--"+flag.Name, src)
+	if err != nil {
+		return nil, fmt.Errorf("invalid cue constraint on --%s: %w", flag.Name, err)
+	}
+	return expr, nil
+}
+
+// checkConstraintTags verifies that every cue:"..." tag in the model parses.
+func checkConstraintTags(node *kong.Node) error {
+	for _, flag := range node.Flags {
+		if _, err := parseConstraint(flag); err != nil {
+			return err
+		}
+	}
+	for _, child := range node.Children {
+		if err := checkConstraintTags(child); err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
 // wrapInClose wraps a struct in close() to reject unknown fields.
@@ -282,24 +501,6 @@ func toDecls(fields []any) []ast.Decl {
 	return decls
 }
 
-// formatValidationError formats CUE validation errors for user display.
-func formatValidationError(err error) error {
-	errStr := err.Error()
-
-	// Check for "field not allowed" pattern (unknown fields)
-	if strings.Contains(errStr, "field not allowed") {
-		return fmt.Errorf("unknown configuration key: %w\n"+
-			"Hint: Check that all config keys correspond to valid CLI flags", err)
-	}
-
-	// Check for "incomplete value" pattern (missing required fields)
-	if strings.Contains(errStr, "incomplete value") {
-		return fmt.Errorf("missing required configuration field: %w", err)
-	}
-
-	return fmt.Errorf("configuration validation failed: %w", err)
-}
-
 // GenerateSchemaWithDefinitions creates a CUE file with named definitions.
 // Each command becomes a separate definition (e.g., #Agent, #Server).
 // The root schema references these definitions.
@@ -384,6 +585,9 @@ func GenerateSchemaFile(app *kong.Application, opts *schemaOptions) *ast.File {
 		file.Decls = append(file.Decls, defField)
 	}
 
+	// Add the Go-typed sections registered with Section
+	file.Decls = append(file.Decls, opts.sectionDefinitions()...)
+
 	return file
 }
 
@@ -395,8 +599,8 @@ func collectCommandDefinitions(node *kong.Node, path []string, defs map[string]c
 			continue
 		}
 
-		// Skip ConfigDoc command as it's not a config option
-		if isConfigDocCommand(child) {
+		// Skip commands like ConfigDoc as they're not config options
+		if excludedFromSchema(child) {
 			continue
 		}
 
@@ -413,8 +617,8 @@ func collectCommandDefinitions(node *kong.Node, path []string, defs map[string]c
 				continue
 			}
 
-			// Skip ConfigDoc in nested commands too
-			if isConfigDocCommand(grandchild) {
+			// Skip them in nested commands too
+			if excludedFromSchema(grandchild) {
 				continue
 			}
 
@@ -442,6 +646,7 @@ func collectCommandDefinitions(node *kong.Node, path []string, defs map[string]c
 				}
 			}
 		}
+		structLit.Elts = append(structLit.Elts, opts.sectionFields(dotPath, existingFields)...)
 		for _, allowed := range opts.allowUnknownPaths {
 			fieldName := opts.getAllowedFieldAtPath(allowed, dotPath)
 			if fieldName != "" && !existingFields[fieldName] {
@@ -467,31 +672,26 @@ func buildFlagsStruct(node *kong.Node, opts *schemaOptions) *ast.StructLit {
 	var fields []ast.Decl
 
 	for _, flag := range node.Flags {
-		if flag.Hidden || flag.Name == "config" || flag.Name == "help" || flag.Name == "help-all" {
+		if !isConfigFlag(flag) {
 			continue
 		}
 
 		fieldName := kebabToSnake(flag.Name)
-		var fieldType ast.Expr
-		if opts.permissiveTypes {
-			fieldType = ast.NewIdent("_")
-		} else {
-			fieldType = valueToType(flag.Value)
-		}
-
 		field := &ast.Field{
 			Label: ast.NewIdent(fieldName),
-			Value: fieldType,
+			Value: flagType(flag, opts),
 		}
-		// Only mark as optional if not required
-		if !flag.Required {
+		// Only mark as optional if not required, or if it may be set by an
+		// old name instead
+		if !flag.Required || len(flag.Aliases) > 0 {
 			field.Constraint = token.OPTION
 		}
 		// Add help text as comment
-		if flag.Help != "" {
-			addDocComment(field, flag.Help)
+		if doc := flagDocComment(flag); doc != "" {
+			addDocComment(field, doc)
 		}
 		fields = append(fields, field)
+		fields = append(fields, aliasFields(flag, opts)...)
 	}
 
 	return &ast.StructLit{Elts: fields}
@@ -505,31 +705,42 @@ func buildRootWithReferences(node *kong.Node, defs map[string]commandDef, opts *
 
 	// Add global flags
 	for _, flag := range node.Flags {
-		if flag.Hidden || flag.Name == "config" || flag.Name == "help" || flag.Name == "help-all" {
+		if !isConfigFlag(flag) {
 			continue
 		}
 
 		fieldName := kebabToSnake(flag.Name)
 		existingFields[fieldName] = true
-		var fieldType ast.Expr
-		if opts.permissiveTypes {
-			fieldType = ast.NewIdent("_")
-		} else {
-			fieldType = valueToType(flag.Value)
+		for _, alias := range flag.Aliases {
+			existingFields[kebabToSnake(alias)] = true
 		}
-
 		field := &ast.Field{
 			Label: ast.NewIdent(fieldName),
-			Value: fieldType,
+			Value: flagType(flag, opts),
 		}
-		// Only mark as optional if not required
-		if !flag.Required {
+		// Only mark as optional if not required, or if it may be set by an
+		// old name instead
+		if !flag.Required || len(flag.Aliases) > 0 {
 			field.Constraint = token.OPTION
 		}
 		// Add help text as comment
-		if flag.Help != "" {
-			addDocComment(field, flag.Help)
+		if doc := flagDocComment(flag); doc != "" {
+			addDocComment(field, doc)
+		}
+		fields = append(fields, field)
+		fields = append(fields, aliasFields(flag, opts)...)
+	}
+
+	// Versioned configs may name the version they were written for
+	if latest := opts.latestConfigVersion(); latest > 0 {
+		existingFields[versionKey] = true
+		field := &ast.Field{
+			Label:      ast.NewIdent(versionKey),
+			Constraint: token.OPTION,
+			Value: ast.NewBinExpr(token.AND, ast.NewIdent("int"),
+				&ast.UnaryExpr{Op: token.LEQ, X: ast.NewLit(token.INT, strconv.Itoa(latest))}),
 		}
+		addDocComment(field, "Config file format version, used to upgrade old configs.")
 		fields = append(fields, field)
 	}
 
@@ -539,8 +750,8 @@ func buildRootWithReferences(node *kong.Node, defs map[string]commandDef, opts *
 			continue
 		}
 
-		// Skip config-doc command (ConfigDoc) as it's not a config option
-		if isConfigDocCommand(child) {
+		// Skip commands like config-doc (ConfigDoc) as they're not config options
+		if excludedFromSchema(child) {
 			continue
 		}
 
@@ -559,6 +770,8 @@ func buildRootWithReferences(node *kong.Node, defs map[string]commandDef, opts *
 		fields = append(fields, refField)
 	}
 
+	fields = append(fields, opts.sectionFields("", existingFields)...)
+
 	// Add allowed paths that don't exist as commands/flags at root level
 	for _, allowed := range opts.allowUnknownPaths {
 		fieldName := opts.getAllowedFieldAtPath(allowed, "")
@@ -576,14 +789,94 @@ func buildRootWithReferences(node *kong.Node, defs map[string]commandDef, opts *
 	return &ast.StructLit{Elts: fields}
 }
 
-// isConfigDocCommand checks if a node is the ConfigDoc command.
-// We skip this in schema generation as it's not a config option.
-func isConfigDocCommand(node *kong.Node) bool {
-	// Check if this is a ConfigDoc command by looking at the target type
-	if node.Target.IsValid() && node.Target.Type().String() == "kongcue.ConfigDoc" {
+// actionFlagTypes are kongcue flag types that perform an action instead of
+// configuring the application.
+var actionFlagTypes = map[reflect.Type]bool{
+	reflect.TypeFor[PrintArgs]():  true,
+	reflect.TypeFor[SaveConfig](): true,
+}
+
+// isConfigFlag reports whether a flag can be set from config files.
+// Hidden flags (unless they are deprecated, so old configs still load), the
+// config flag itself, help flags and actionFlagTypes are excluded.
+func isConfigFlag(flag *kong.Flag) bool {
+	if (flag.Hidden && !flag.Tag.Has(deprecatedTag)) || flag.Name == "config" || flag.Name == "help" || flag.Name == "help-all" {
+		return false
+	}
+	return !flag.Target.IsValid() || !actionFlagTypes[flag.Target.Type()]
+}
+
+// ConfigCommand is implemented by commands that work on the config rather
+// than being configured by it, like ConfigDoc and ConfigValidate, and by
+// commands that must run even when the config is broken, like version or
+// completion commands. When SkipsConfig returns true, the config isn't
+// loaded or validated when the command runs, and the command is left out of
+// the config schema unless it implements SchemaExcluder:
+//
+//	type versionCmd struct{}
+//
+//	func (versionCmd) SkipsConfig() bool { return true }
+type ConfigCommand interface {
+	SkipsConfig() bool
+}
+
+// SchemaExcluder is implemented by commands that decide for themselves
+// whether they're left out of the config schema, such as ConfigShow, which
+// needs the config loaded but isn't configured by it. It takes precedence
+// over SkipsConfig.
+type SchemaExcluder interface {
+	ExcludedFromSchema() bool
+}
+
+// configCommand returns the command of a node as a ConfigCommand, if it
+// implements it with either a value or pointer receiver.
+func configCommand(node *kong.Node) (ConfigCommand, bool) {
+	cmd, ok := nodeCommand(node).(ConfigCommand)
+	return cmd, ok
+}
+
+// nodeCommand returns a pointer to the command of a node, or nil if there
+// is none, so it can be checked for interfaces implemented with either a
+// value or pointer receiver.
+func nodeCommand(node *kong.Node) any {
+	if node == nil || !node.Target.IsValid() {
+		return nil
+	}
+	target := node.Target
+	if target.CanAddr() {
+		target = target.Addr()
+	}
+	if !target.CanInterface() {
+		return nil
+	}
+	return target.Interface()
+}
+
+// excludedFromSchema checks if a node is left out of the config schema by
+// its SchemaExcluder or ConfigCommand implementation, or is a command that
+// only groups such commands (like "config" for "config set"). We skip these
+// in schema generation as they're not config options.
+func excludedFromSchema(node *kong.Node) bool {
+	if cmd, ok := nodeCommand(node).(SchemaExcluder); ok {
+		return cmd.ExcludedFromSchema()
+	}
+	if cmd, ok := configCommand(node); ok && cmd.SkipsConfig() {
 		return true
 	}
-	return false
+	if len(node.Children) == 0 {
+		return false
+	}
+	for _, flag := range node.Flags {
+		if isConfigFlag(flag) {
+			return false
+		}
+	}
+	for _, child := range node.Children {
+		if !excludedFromSchema(child) {
+			return false
+		}
+	}
+	return true
 }
 
 // pascalCase converts snake_case or kebab-case to PascalCase.
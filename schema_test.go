@@ -420,3 +420,232 @@ func TestBeforeResolve_AcceptsValidConfig(t *testing.T) {
 		t.Errorf("expected name 'Brian', got %q", cli.Name)
 	}
 }
+
+type constraintCLI struct {
+	Port   int      `name:"port" cue:">=1 & <=65535" help:"Listen port"`
+	CaURL  string   `name:"ca-url" cue:"=~\"^https://\""`
+	Mode   string   `name:"mode" cue:"\"fast\" | \"slow\""`
+	Hosts  []string `name:"hosts" cue:"[...=~\"\\\\.example\\\\.com$\"]"`
+	Config kongcue.Config
+}
+
+func TestConstraintTags_Accepted(t *testing.T) {
+	dir := t.TempDir()
+	configFile := filepath.Join(dir, "config.yaml")
+	if err := os.WriteFile(configFile, []byte(`
+port: 8443
+ca_url: "https://ca.example.com"
+mode: slow
+hosts: ["a.example.com"]
+`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	var cli constraintCLI
+	parser, err := kong.New(&cli, kongcue.Options())
+	if err != nil {
+		t.Fatalf("failed to create parser: %v", err)
+	}
+	if _, err := parser.Parse([]string{"--config", configFile}); err != nil {
+		t.Fatalf("valid config should not produce error: %v", err)
+	}
+	if cli.Port != 8443 || cli.Mode != "slow" {
+		t.Errorf("unexpected values: port=%d mode=%q", cli.Port, cli.Mode)
+	}
+}
+
+func TestConstraintTags_Rejected(t *testing.T) {
+	tests := []struct {
+		name   string
+		config string
+		want   string
+	}{
+		{"range", "port: 70000", "port"},
+		{"pattern", `ca_url: "http://ca.example.com"`, "ca_url"},
+		{"disjunction", "mode: medium", "mode"},
+		{"list elements", `hosts: ["a.example.org"]`, "hosts"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			configFile := filepath.Join(dir, "config.yaml")
+			if err := os.WriteFile(configFile, []byte(tt.config), 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			var cli constraintCLI
+			parser, err := kong.New(&cli, kongcue.Options())
+			if err != nil {
+				t.Fatalf("failed to create parser: %v", err)
+			}
+			_, err = parser.Parse([]string{"--config", configFile})
+			if err == nil {
+				t.Fatal("expected constraint violation")
+			}
+			if !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("error should mention %s, got: %v", tt.want, err)
+			}
+			if !strings.Contains(err.Error(), "config.yaml:1") {
+				t.Errorf("error should include file position, got: %v", err)
+			}
+		})
+	}
+}
+
+func TestConstraintTags_Invalid(t *testing.T) {
+	var cli struct {
+		Port int `name:"port" cue:">=1 &"`
+	}
+	parser, err := kong.New(&cli)
+	if err != nil {
+		t.Fatalf("failed to create parser: %v", err)
+	}
+
+	config, _ := kongcue.LoadAndUnifyPaths([]string{})
+	_, err = kongcue.GenerateSchema(config.Context(), parser.Model, nil)
+	if err == nil {
+		t.Fatal("expected error for malformed cue tag")
+	}
+	if !strings.Contains(err.Error(), "--port") {
+		t.Errorf("error should name the flag, got: %v", err)
+	}
+}
+
+func TestValidateConfig_SizedIntegers(t *testing.T) {
+	var cli struct {
+		Level  uint8     `name:"level"`
+		Offset int16     `name:"offset"`
+		Count  uint      `name:"count"`
+		Ratio  float32   `name:"ratio"`
+		Ports  []uint16  `name:"ports"`
+		Scales []float64 `name:"scales"`
+	}
+	parser, err := kong.New(&cli)
+	if err != nil {
+		t.Fatalf("failed to create parser: %v", err)
+	}
+
+	config, _ := kongcue.LoadAndUnifyPaths([]string{})
+	schema, err := kongcue.GenerateSchema(config.Context(), parser.Model, nil)
+	if err != nil {
+		t.Fatalf("failed to generate schema: %v", err)
+	}
+
+	tests := []struct {
+		config string
+		valid  bool
+	}{
+		{"level: 255", true},
+		{"level: 300", false},
+		{"level: -5", false},
+		{"offset: -32768", true},
+		{"offset: 40000", false},
+		{"count: 1000000", true},
+		{"count: -1", false},
+		{"ratio: 1.5", true},
+		{"ratio: 2", true},
+		{"ports: [80, 443]", true},
+		{"ports: [80, 70000]", false},
+		{"scales: [0.5, 1]", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.config, func(t *testing.T) {
+			val := config.Context().CompileString(tt.config)
+			err := kongcue.ValidateConfig(schema, val)
+			if tt.valid && err != nil {
+				t.Errorf("expected %q to be valid: %v", tt.config, err)
+			}
+			if !tt.valid && err == nil {
+				t.Errorf("expected %q to be rejected", tt.config)
+			}
+		})
+	}
+}
+
+type scopedCLI struct {
+	Config kongcue.Config `default:"./config.yaml"`
+	Name   string         `name:"name"`
+	Client struct {
+		URL string `name:"url"`
+	} `cmd:""`
+	Server struct {
+		Port int    `name:"port"`
+		Key  string `name:"key" required:""`
+		TLS  struct {
+			Cert string `name:"cert"`
+		} `cmd:""`
+		Run struct{} `cmd:""`
+	} `cmd:""`
+}
+
+func TestSelectedCommandOnly(t *testing.T) {
+	config := "name: x\nclient:\n  url: https://example.com\nserver:\n  port: eighty\n  bogus: 1\n"
+
+	if _, err := kongcue.ParseWithConfig(t, &scopedCLI{}, config, []kong.Option{kong.Bind(&kongcue.SchemaOptions{})}, "client"); err == nil {
+		t.Error("expected the broken server section to fail without SelectedCommandOnly")
+	}
+
+	opts := &kongcue.SchemaOptions{SelectedCommandOnly: true}
+	var cli scopedCLI
+	_, err := kongcue.ParseWithConfig(t, &cli, config, []kong.Option{kong.Bind(opts)}, "client")
+	if err != nil {
+		t.Fatalf("broken server section should not affect client: %v", err)
+	}
+	if cli.Client.URL != "https://example.com" || cli.Name != "x" {
+		t.Errorf("expected values from config, got url %q, name %q", cli.Client.URL, cli.Name)
+	}
+
+	_, err = kongcue.ParseWithConfig(t, &scopedCLI{}, config, []kong.Option{kong.Bind(opts)}, "server", "run")
+	if err == nil || !strings.Contains(err.Error(), "server.port") || !strings.Contains(err.Error(), "server.bogus") {
+		t.Errorf("expected errors for the selected command's section, got %v", err)
+	}
+}
+
+func TestSelectedCommandOnly_Siblings(t *testing.T) {
+	opts := &kongcue.SchemaOptions{SelectedCommandOnly: true}
+	config := "server:\n  key: k\n  run: {}\n  tls:\n    cert: 1\n"
+
+	if _, err := kongcue.ParseWithConfig(t, &scopedCLI{}, config, []kong.Option{kong.Bind(opts)}, "server", "run"); err != nil {
+		t.Errorf("broken sibling section should not affect server run: %v", err)
+	}
+	if _, err := kongcue.ParseWithConfig(t, &scopedCLI{}, "server:\n  run: {}\n", []kong.Option{kong.Bind(opts)}, "server", "run"); err == nil || !strings.Contains(err.Error(), "server.key") {
+		t.Errorf("expected parent command's required flag to be checked, got %v", err)
+	}
+}
+
+func TestSelectedCommandOnly_UnknownTopLevelKey(t *testing.T) {
+	opts := &kongcue.SchemaOptions{SelectedCommandOnly: true}
+	_, err := kongcue.ParseWithConfig(t, &scopedCLI{}, "nmae: x\n", []kong.Option{kong.Bind(opts)}, "client")
+	if err == nil || !strings.Contains(err.Error(), "nmae: unknown key; did you mean name?") {
+		t.Errorf("expected unknown top-level keys to be reported, got %v", err)
+	}
+}
+
+func TestSchemaOptions_Combine(t *testing.T) {
+	config := "extra:\n  anything: 1\nclient:\n  url: https://example.com\nserver:\n  port: eighty\n"
+	dir := t.TempDir()
+	t.Chdir(dir)
+	kongcue.WriteConfig(t, dir, "config.yaml", config)
+
+	for name, options := range map[string][]kong.Option{
+		"helpers":      {kongcue.AllowUnknownFields("extra"), kongcue.SelectedCommandOnly()},
+		"reversed":     {kongcue.SelectedCommandOnly(), kongcue.AllowUnknownFields("extra")},
+		"with Options": {kongcue.SelectedCommandOnly(), kongcue.Options(), kongcue.AllowUnknownFields("extra"), kongcue.Options()},
+	} {
+		t.Run(name, func(t *testing.T) {
+			var cli scopedCLI
+			parser, err := kong.New(&cli, options...)
+			if err != nil {
+				t.Fatalf("failed to create parser: %v", err)
+			}
+			if _, err := parser.Parse([]string{"client"}); err != nil {
+				t.Fatalf("expected the options to combine: %v", err)
+			}
+			if cli.Client.URL != "https://example.com" {
+				t.Errorf("expected url from config, got %q", cli.Client.URL)
+			}
+		})
+	}
+}
@@ -0,0 +1,222 @@
+package kongcue
+
+import (
+	"fmt"
+	"maps"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+
+	"cuelang.org/go/cue"
+	"cuelang.org/go/cue/ast"
+	"cuelang.org/go/cue/cuecontext"
+	"cuelang.org/go/cue/token"
+	"github.com/alecthomas/kong"
+)
+
+var (
+	registeredSectionsMu sync.RWMutex
+	registeredSections   = map[string]reflect.Type{} // keyed by dot-separated config path
+)
+
+// Section registers a config section that isn't made of flags, with a
+// schema generated from the Go type of v. path is the section's config key,
+// such as "plugins", or a key under a command's section, such as
+// "server.plugins". The section is added to the schema as a definition, so
+// it's documented by ConfigDoc and validated when the config is loaded, and
+// it can be read back with Decode:
+//
+//	type PluginsConfig struct {
+//	    Enabled []string `json:"enabled"`
+//	    Timeout int      `json:"timeout,omitempty" cue:">0,opt"`
+//	}
+//
+//	func init() {
+//	    kongcue.Section("plugins", PluginsConfig{})
+//	}
+//
+// The schema follows CUE's Context.EncodeType: keys are taken from json
+// struct tags and cue:"..." struct tags add constraints. Fields tagged
+// omitempty are optional, except that fields with a cue tag need ",opt" in
+// it instead. Section is meant to be called from an init function,
+// and panics if no schema can be generated for v or if a section is
+// already registered at path. Use WithSection to add a section to one
+// application only.
+func Section(path string, v any) {
+	typ, err := sectionType(path, v)
+	if err != nil {
+		panic(err.Error())
+	}
+
+	registeredSectionsMu.Lock()
+	defer registeredSectionsMu.Unlock()
+	if _, ok := registeredSections[path]; ok {
+		panic(fmt.Sprintf("kongcue: section %q registered twice", path))
+	}
+	registeredSections[path] = typ
+}
+
+// WithSection returns a Kong option that adds a config section like
+// Section, but only to the application it's passed to. It takes precedence
+// over a section registered with Section at the same path.
+//
+//	kong.Parse(&cli, kongcue.WithSection("plugins", PluginsConfig{}))
+//
+// Creating the parser fails if no schema can be generated for v or if the
+// option is passed twice for path.
+func WithSection(path string, v any) kong.Option {
+	return schemaOption(func(opts *SchemaOptions) error {
+		typ, err := sectionType(path, v)
+		if err != nil {
+			return err
+		}
+		if _, ok := opts.sections[path]; ok {
+			return fmt.Errorf("kongcue: section %q added twice", path)
+		}
+		if opts.sections == nil {
+			opts.sections = map[string]reflect.Type{}
+		}
+		opts.sections[path] = typ
+		return nil
+	})
+}
+
+// sectionType checks a section's path and returns the Go type of v, which
+// a schema can be generated for.
+func sectionType(path string, v any) (reflect.Type, error) {
+	if err := cue.ParsePath(path).Err(); err != nil || path == "" {
+		return nil, fmt.Errorf("kongcue: invalid section path %q", path)
+	}
+	typ := reflect.TypeOf(v)
+	if typ == nil {
+		return nil, fmt.Errorf("kongcue: nil value for section %q", path)
+	}
+	for typ.Kind() == reflect.Pointer {
+		typ = typ.Elem()
+	}
+	if _, err := sectionSchema(typ); err != nil {
+		return nil, fmt.Errorf("kongcue: section %q: %v", path, err)
+	}
+	return typ, nil
+}
+
+// sectionSchema generates the CUE schema for a section's Go type.
+func sectionSchema(typ reflect.Type) (ast.Expr, error) {
+	val := cuecontext.New().EncodeType(reflect.Zero(typ).Interface())
+	if err := val.Err(); err != nil {
+		return nil, err
+	}
+	expr, ok := val.Syntax(cue.Docs(true)).(ast.Expr)
+	if !ok {
+		return nil, fmt.Errorf("cannot generate a schema for %s", typ)
+	}
+	return expr, nil
+}
+
+// sectionTypes returns the sections registered with Section and those
+// added with WithSection, keyed by config path.
+func (opts *schemaOptions) sectionTypes() map[string]reflect.Type {
+	registeredSectionsMu.RLock()
+	types := maps.Clone(registeredSections)
+	registeredSectionsMu.RUnlock()
+	if opts != nil {
+		maps.Copy(types, opts.sections)
+	}
+	return types
+}
+
+// sectionsAt returns the keys of the sections directly under a
+// dot-separated command path, in sorted order. The root path is "".
+func (opts *schemaOptions) sectionsAt(parent string) []string {
+	var keys []string
+	for path := range opts.sectionTypes() {
+		dir, key := splitSectionPath(path)
+		if dir == parent {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// splitSectionPath splits a section path into its parent command path and
+// its key: "server.plugins" -> ("server", "plugins").
+func splitSectionPath(path string) (parent, key string) {
+	if i := strings.LastIndex(path, "."); i >= 0 {
+		return path[:i], path[i+1:]
+	}
+	return "", path
+}
+
+// sectionDefName returns the schema definition name of a section:
+// "server.plugins" -> "ServerPluginsSection".
+func sectionDefName(path string) string {
+	return commandDefName(strings.Split(path, ".")) + "Section"
+}
+
+// sectionFields returns the schema fields referring to the sections under
+// a command path, skipping keys already taken by flags or commands.
+func (opts *schemaOptions) sectionFields(parent string, existing map[string]bool) []ast.Decl {
+	var fields []ast.Decl
+	for _, key := range opts.sectionsAt(parent) {
+		if existing[key] {
+			continue // Reported by checkSections
+		}
+		existing[key] = true
+		path := key
+		if parent != "" {
+			path = parent + "." + key
+		}
+		fields = append(fields, &ast.Field{
+			Label:      ast.NewIdent(key),
+			Constraint: token.OPTION,
+			Value:      ast.NewIdent("#" + sectionDefName(path)),
+		})
+	}
+	return fields
+}
+
+// sectionDefinitions returns the schema definitions of the sections, or _
+// for each when types are permissive.
+func (opts *schemaOptions) sectionDefinitions() []ast.Decl {
+	types := opts.sectionTypes()
+	var defs []ast.Decl
+	for _, path := range sortedKeys(types) {
+		var value ast.Expr = ast.NewIdent("_")
+		if !opts.permissiveTypes {
+			expr, err := sectionSchema(types[path])
+			if err != nil {
+				continue // Checked by Section and WithSection
+			}
+			value = expr
+		}
+		field := &ast.Field{
+			Label: ast.NewIdent("#" + sectionDefName(path)),
+			Value: value,
+		}
+		addDocComment(field, fmt.Sprintf("%s is decoded into %s.", path, types[path]))
+		defs = append(defs, field)
+	}
+	return defs
+}
+
+// checkSections verifies that every section is under a command of the
+// application and doesn't share its key with a flag or command.
+func checkSections(app *kong.Application, opts *schemaOptions) error {
+	tree := buildConfigTree(app, opts)
+	for _, path := range sortedKeys(opts.sectionTypes()) {
+		parent, key := splitSectionPath(path)
+		section, err := tree.find(parent)
+		if err != nil {
+			return fmt.Errorf("config section %q: %w", path, err)
+		}
+		if section.child(key) != nil {
+			return fmt.Errorf("config section %q conflicts with a command", path)
+		}
+		if _, flag := section.lookup([]string{key}); flag != nil {
+			return fmt.Errorf("config section %q conflicts with --%s", path, flag.Name)
+		}
+	}
+	return nil
+}
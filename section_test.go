@@ -0,0 +1,173 @@
+package kongcue
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"cuelang.org/go/cue/format"
+	"github.com/alecthomas/kong"
+)
+
+type pluginsConfig struct {
+	Enabled []string          `json:"enabled"`
+	Timeout int               `json:"timeout,omitempty" cue:">0,opt"`
+	Options map[string]string `json:"options,omitempty"`
+}
+
+type sectionCLI struct {
+	Config Config `default:"./config.yaml"`
+	Name   string `name:"name"`
+	Server struct {
+		Port int `name:"port"`
+	} `cmd:""`
+}
+
+// registerSection registers a global section for the duration of a test.
+func registerSection(t *testing.T, path string, v any) {
+	t.Helper()
+	restoreRegistries(t)
+	Section(path, v)
+}
+
+func TestSection_Schema(t *testing.T) {
+	ctx, err := ParseWithConfig(t, &sectionCLI{}, "", []kong.Option{WithSection("plugins", pluginsConfig{}), WithSection("server.hooks", &pluginsConfig{})}, "server")
+	if err != nil {
+		t.Fatalf("failed to parse: %v", err)
+	}
+	opts := schemaOptionsFrom(ctx).toInternal()
+
+	src, err := format.Node(GenerateSchemaFile(ctx.Model, opts))
+	if err != nil {
+		t.Fatalf("failed to format schema: %v", err)
+	}
+	for _, want := range []string{
+		"plugins?: #PluginsSection",
+		"hooks?: #ServerHooksSection",
+		"#PluginsSection: {",
+		"timeout?: int64 & >0",
+		"enabled: *null | [...string]",
+		"// plugins is decoded into kongcue.pluginsConfig.",
+	} {
+		if !strings.Contains(string(src), want) {
+			t.Errorf("expected %q in schema:\n%s", want, src)
+		}
+	}
+
+	doc, err := renderConfigDoc(ctx.Model, opts, "markdown", "")
+	if err != nil {
+		t.Fatalf("failed to render markdown: %v", err)
+	}
+	if !strings.Contains(string(doc), "| `server.hooks` | `#ServerHooksSection` |") {
+		t.Errorf("expected the section in the markdown docs:\n%s", doc)
+	}
+	doc, err = renderConfigDoc(ctx.Model, opts, "cue", "server")
+	if err != nil {
+		t.Fatalf("failed to render the server schema: %v", err)
+	}
+	if !strings.Contains(string(doc), "#ServerHooksSection:") || strings.Contains(string(doc), "#PluginsSection:") {
+		t.Errorf("expected only the server's sections:\n%s", doc)
+	}
+}
+
+func TestSection_Validated(t *testing.T) {
+	tests := []struct {
+		config string
+		kind   IssueKind
+		path   string
+	}{
+		{"plugins:\n  enabled: [a]\n  timeout: 0\n", IssueInvalidValue, "plugins.timeout"},
+		{"plugins:\n  enabled: [a]\n  timeout: five\n", IssueTypeMismatch, "plugins.timeout"},
+		{"plugins:\n  enabled: [a]\n  timout: 5\n", IssueUnknownKey, "plugins.timout"},
+	}
+	for _, tt := range tests {
+		_, err := ParseWithConfig(t, &sectionCLI{}, tt.config, []kong.Option{WithSection("plugins", pluginsConfig{})}, "server")
+		var verr *ValidationError
+		if !errors.As(err, &verr) || len(verr.Issues) == 0 {
+			t.Errorf("config %q: expected a ValidationError, got %v", tt.config, err)
+			continue
+		}
+		if issue := verr.Issues[0]; issue.Kind != tt.kind || issue.Path != tt.path {
+			t.Errorf("config %q: expected %s at %s, got %+v", tt.config, tt.kind, tt.path, issue)
+		}
+	}
+}
+
+func TestSection_Decode(t *testing.T) {
+	ctx, err := ParseWithConfig(t, &sectionCLI{}, "plugins:\n  enabled: [a, b]\n  options: {k: v}\n", []kong.Option{WithSection("plugins", pluginsConfig{})}, "server")
+	if err != nil {
+		t.Fatalf("failed to parse: %v", err)
+	}
+	plugins, err := Lookup[pluginsConfig](ctx, "plugins")
+	if err != nil {
+		t.Fatalf("failed to decode: %v", err)
+	}
+	if len(plugins.Enabled) != 2 || plugins.Options["k"] != "v" {
+		t.Errorf("unexpected value %+v", plugins)
+	}
+}
+
+func TestSection_Conflicts(t *testing.T) {
+	tests := []struct {
+		path, want string
+	}{
+		{"name", `config section "name" conflicts with --name`},
+		{"server", `config section "server" conflicts with a command`},
+		{"client.plugins", `config section "client.plugins": unknown command "client"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			_, err := ParseWithConfig(t, &sectionCLI{}, "name: x\n", []kong.Option{WithSection(tt.path, pluginsConfig{})}, "server")
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("expected %q, got %v", tt.want, err)
+			}
+		})
+	}
+}
+
+func TestSection_Registered(t *testing.T) {
+	registerSection(t, "plugins", pluginsConfig{})
+
+	if _, err := ParseWithConfig(t, &sectionCLI{}, "plugins:\n  timeout: 0\n", []kong.Option{Options()}, "server"); err == nil || !strings.Contains(err.Error(), "plugins.timeout") {
+		t.Errorf("expected the registered section to be validated, got %v", err)
+	}
+
+	// Sections added to the application replace registered ones
+	type otherPlugins struct {
+		Timeout string `json:"timeout"`
+	}
+	if _, err := ParseWithConfig(t, &sectionCLI{}, "plugins:\n  timeout: soon\n", []kong.Option{WithSection("plugins", otherPlugins{})}, "server"); err != nil {
+		t.Errorf("expected the application's section to be used: %v", err)
+	}
+}
+
+func TestWithSection_Errors(t *testing.T) {
+	var cli sectionCLI
+	for name, options := range map[string][]kong.Option{
+		"duplicate": {WithSection("plugins", pluginsConfig{}), WithSection("plugins", pluginsConfig{})},
+		"nil":       {WithSection("other", nil)},
+		"empty":     {WithSection("", pluginsConfig{})},
+	} {
+		if _, err := kong.New(&cli, options...); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
+
+func TestSection_Panics(t *testing.T) {
+	registerSection(t, "plugins", pluginsConfig{})
+	for name, fn := range map[string]func(){
+		"duplicate": func() { Section("plugins", pluginsConfig{}) },
+		"nil":       func() { Section("other", nil) },
+		"empty":     func() { Section("", pluginsConfig{}) },
+	} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("%s: expected a panic", name)
+				}
+			}()
+			fn()
+		}()
+	}
+}
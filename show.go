@@ -0,0 +1,414 @@
+package kongcue
+
+import (
+	"bytes"
+	"encoding"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"slices"
+	"strings"
+
+	"cuelang.org/go/cue"
+	"cuelang.org/go/cue/token"
+	"github.com/alecthomas/kong"
+)
+
+// secretTag marks flags whose values are redacted when showing config,
+// e.g. `secret:""`.
+const secretTag = "secret"
+
+// redacted replaces the values of secret flags.
+const redacted = "<redacted>"
+
+// Effective config formats supported by ShowConfig.
+var showFormats = []string{"yaml", "json", "cue"}
+
+// ShowOptions controls how ShowConfig renders the effective config.
+type ShowOptions struct {
+	// Command restricts the output to the settings seen by one command
+	// (e.g. "agent.tls"): the global flags, the flags of its parent
+	// commands, and its own section. Empty shows everything.
+	Command string
+
+	// Format is "yaml" (the default), "json" or "cue".
+	Format string
+
+	// Sources annotates each value with where it came from: a config file
+	// position, a command line flag, an environment variable or the default.
+	Sources bool
+}
+
+// ShowConfig renders the effective configuration: the value each config key
+// ends up with after config files, environment variables, defaults and
+// command line flags are combined. Keys without a value are left out, and
+// flags tagged `secret:""` are redacted.
+//
+// ctx is the parse context and config the loaded config value, as bound by
+// kongcue.Config. It can be called from a command's Run method, or use the
+// ConfigShow command.
+func ShowConfig(ctx *kong.Context, config cue.Value, opts ShowOptions) ([]byte, error) {
+	tree := buildConfigTree(ctx.Model, schemaOptionsFrom(ctx).toInternal())
+	section, err := tree.find(opts.Command)
+	if err != nil {
+		return nil, err
+	}
+	effective := effectiveSection(ctx, config, tree, section.path)
+
+	var buf bytes.Buffer
+	switch opts.Format {
+	case "", "yaml", "yml":
+		writeEffective(&buf, effective, yamlSyntax, "", opts.Sources)
+	case "cue":
+		writeEffective(&buf, effective, cueSyntax, "", opts.Sources)
+	case "json":
+		var doc any = effective.native()
+		if opts.Sources {
+			sources := map[string]string{}
+			effective.collectSources(sources)
+			doc = map[string]any{"config": doc, "sources": sources}
+		}
+		src, err := json.MarshalIndent(doc, "", "  ")
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(src)
+		buf.WriteByte('\n')
+	default:
+		return nil, fmt.Errorf("unknown format %q (expected one of %s)", opts.Format, strings.Join(showFormats, ", "))
+	}
+	return buf.Bytes(), nil
+}
+
+// effectiveValue is the value of one config key and where it came from.
+type effectiveValue struct {
+	key    string
+	value  any
+	source string
+}
+
+// effective is the resolved counterpart of a configSection.
+type effective struct {
+	path     []string
+	values   []effectiveValue
+	children []*effective
+}
+
+// effectiveSection resolves the values of a config section. Only the
+// children leading to target (and all children below it) are included.
+func effectiveSection(ctx *kong.Context, config cue.Value, section *configSection, target []string) *effective {
+	out := &effective{path: section.path}
+	for _, flag := range section.flags {
+		value, source, ok := effectiveFlag(ctx, config, section.path, flag, section.opts)
+		if !ok {
+			continue
+		}
+		if flag.Tag.Has(secretTag) {
+			value = redacted
+		}
+		out.values = append(out.values, effectiveValue{key: kebabToSnake(flag.Name), value: value, source: source})
+	}
+	depth := len(section.path)
+	for _, child := range section.children {
+		if depth < len(target) && child.path[depth] != target[depth] {
+			continue
+		}
+		if e := effectiveSection(ctx, config, child, target); !e.empty() {
+			out.children = append(out.children, e)
+		}
+	}
+	return out
+}
+
+// effectiveFlag returns the value a flag ends up with and its source.
+// Returns false if the flag has no value.
+//
+// The value is the one Kong parsed, so it's exactly what the command sees;
+// the command line, config, environment and default are only consulted, in
+// Kong's order of precedence, to tell where it came from. Kong doesn't
+// resolve the flags of commands that weren't selected, so their config
+// values are parsed here the same way.
+func effectiveFlag(ctx *kong.Context, config cue.Value, path []string, flag *kong.Flag, opts *schemaOptions) (any, string, bool) {
+	trace, onPath := flagTrace(ctx, flag)
+	switch {
+	case trace != nil && !trace.Resolved:
+		return goToConfigValue(ctx.FlagValue(flag), flag.Format, opts), "flag --" + flag.Name, true
+	case trace != nil:
+		return goToConfigValue(ctx.FlagValue(flag), flag.Format, opts), configSource(config, path, flag), true
+	case !onPath && config.Exists():
+		if value, source, ok := resolveUnselected(config, path, flag, opts); ok {
+			return value, source, true
+		}
+	}
+
+	for _, env := range flag.Envs {
+		if _, ok := os.LookupEnv(env); ok {
+			return goToConfigValue(ctx.FlagValue(flag), flag.Format, opts), "env $" + env, true
+		}
+	}
+
+	if flag.HasDefault {
+		return goToConfigValue(ctx.FlagValue(flag), flag.Format, opts), "default", true
+	}
+	return nil, "", false
+}
+
+// flagTrace returns the trace of a flag set on the command line or by a
+// resolver, and whether the flag belongs to the selected command or one of
+// its parents.
+func flagTrace(ctx *kong.Context, flag *kong.Flag) (trace *kong.Path, onPath bool) {
+	for _, p := range ctx.Path {
+		if p.Flag == flag {
+			trace = p
+		}
+		if slices.Contains(p.Flags, flag) {
+			onPath = true
+		}
+	}
+	return trace, onPath || trace != nil
+}
+
+// configSource describes where a resolved flag was set in the config.
+func configSource(config cue.Value, path []string, flag *kong.Flag) string {
+	if config.Exists() {
+		if val := lookupFlag(config, path, flag); val.Exists() {
+			return sourcePos(val)
+		}
+	}
+	return "config"
+}
+
+// resolveUnselected parses the config value of a flag Kong didn't resolve,
+// as the resolver would have. Returns false if the config doesn't set the
+// flag.
+func resolveUnselected(config cue.Value, path []string, flag *kong.Flag, opts *schemaOptions) (any, string, bool) {
+	val := lookupFlag(config, path, flag)
+	if !val.Exists() || !val.IsConcrete() {
+		return nil, "", false
+	}
+	resolved, err := resolveValue(val, flag, opts)
+	if err != nil || resolved == nil {
+		return nil, "", false
+	}
+
+	// Start from an empty value, as Kong does before parsing
+	target := reflect.New(flag.Target.Type()).Elem()
+	switch target.Kind() {
+	case reflect.Pointer:
+		target.Set(reflect.New(target.Type().Elem()))
+	case reflect.Slice:
+		target.Set(reflect.MakeSlice(target.Type(), 0, 0))
+	case reflect.Map:
+		target.Set(reflect.MakeMap(target.Type()))
+	default:
+	}
+	if err := flag.Parse(kong.Scan().PushTyped(resolved, kong.FlagValueToken), target); err != nil {
+		return nil, "", false
+	}
+	return goToConfigValue(target.Interface(), flag.Format, opts), sourcePos(val), true
+}
+
+// sourcePos describes the config file position a value was set at.
+func sourcePos(val cue.Value) string {
+	if pos := configPos(val); pos.IsValid() {
+		return fmt.Sprintf("%s:%d", pos.Filename(), pos.Line())
+	}
+	return "config"
+}
+
+// configPos returns the config file position a value was set at. Lists and
+// structs unified with the schema may be positioned in the schema, so the
+// position of their first element is used instead. Returns token.NoPos if
+// the value has no config file position.
+func configPos(val cue.Value) token.Pos {
+	if pos := val.Pos(); pos.Filename() != "" && pos.Filename() != generatedSchemaFilename {
+		return pos
+	}
+	var first cue.Value
+	if iter, err := val.List(); err == nil && iter.Next() {
+		first = iter.Value()
+	} else if iter, err := val.Fields(); err == nil && iter.Next() {
+		first = iter.Value()
+	}
+	if first.Exists() {
+		return configPos(first)
+	}
+	return token.NoPos
+}
+
+// goToConfigValue converts a flag's Go value to the value that would be
+// written in a config file, in a form the flag's mapper parses back. format
+// is the flag's format:"..." tag. Types with a mapping's Encode, a
+// MarshalText or a String method, such as time.Time and net.IP, are written
+// as strings.
+func goToConfigValue(v any, format string, opts *schemaOptions) any {
+	if v == nil {
+		return nil
+	}
+	rv := reflect.ValueOf(v)
+	if m, ok := opts.typeMapping(rv.Type()); ok && m.Encode != nil {
+		for rv.Kind() == reflect.Pointer {
+			if rv.IsNil() {
+				return nil
+			}
+			rv = rv.Elem()
+		}
+		return m.Encode(rv.Interface(), format)
+	}
+	if rv.Kind() == reflect.Pointer && rv.IsNil() {
+		return nil
+	}
+	if m, ok := v.(encoding.TextMarshaler); ok {
+		if text, err := m.MarshalText(); err == nil {
+			return string(text)
+		}
+	}
+	if s, ok := v.(fmt.Stringer); ok {
+		return s.String()
+	}
+	switch rv.Kind() {
+	case reflect.Pointer:
+		return goToConfigValue(rv.Elem().Interface(), format, opts)
+	case reflect.Bool:
+		return rv.Bool()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return rv.Int()
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return rv.Uint()
+	case reflect.Float32, reflect.Float64:
+		return rv.Float()
+	case reflect.String:
+		return rv.String()
+	case reflect.Slice, reflect.Array:
+		items := make([]any, rv.Len())
+		for i := range items {
+			items[i] = goToConfigValue(rv.Index(i).Interface(), format, opts)
+		}
+		return items
+	case reflect.Map:
+		entries := map[string]any{}
+		iter := rv.MapRange()
+		for iter.Next() {
+			entries[fmt.Sprint(iter.Key().Interface())] = goToConfigValue(iter.Value().Interface(), format, opts)
+		}
+		return entries
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// empty reports whether the section and its descendants have no values.
+func (e *effective) empty() bool {
+	return len(e.values) == 0 && len(e.children) == 0
+}
+
+// native returns the section's values as nested maps.
+func (e *effective) native() map[string]any {
+	out := map[string]any{}
+	for _, v := range e.values {
+		out[v.key] = v.value
+	}
+	for _, child := range e.children {
+		out[child.path[len(child.path)-1]] = child.native()
+	}
+	return out
+}
+
+// collectSources adds the source of each value, keyed by its dotted config
+// path.
+func (e *effective) collectSources(sources map[string]string) {
+	for _, v := range e.values {
+		sources[strings.Join(append(append([]string{}, e.path...), v.key), ".")] = v.source
+	}
+	for _, child := range e.children {
+		child.collectSources(sources)
+	}
+}
+
+// writeEffective writes the effective config in YAML or CUE syntax,
+// optionally with the source of each value as a trailing comment.
+func writeEffective(w io.Writer, e *effective, syntax sampleSyntax, indent string, sources bool) {
+	for _, v := range e.values {
+		fmt.Fprintf(w, "%s%s: %s", indent, v.key, flowValue(v.value))
+		if sources {
+			fmt.Fprintf(w, " %s%s", syntax.comment, v.source)
+		}
+		fmt.Fprintln(w)
+	}
+	for _, child := range e.children {
+		fmt.Fprintf(w, "%s%s%s\n", indent, child.path[len(child.path)-1], syntax.open)
+		writeEffective(w, child, syntax, indent+syntax.indent, sources)
+		if syntax.close != "" {
+			fmt.Fprintf(w, "%s%s\n", indent, syntax.close)
+		}
+	}
+}
+
+// ConfigShow is a Kong command that prints the effective configuration:
+// what each config key is set to once config files, environment variables,
+// defaults and command line flags are combined. Embed this in your CLI
+// struct alongside Config to debug where a setting comes from.
+//
+// Flags tagged `secret:""` are redacted in the output.
+//
+// Usage:
+//
+//	type cli struct {
+//	    Config     kongcue.Config     `default:"~/.myapp.yaml"`
+//	    Agent      agentCmd           `cmd:""`
+//	    ConfigShow kongcue.ConfigShow `cmd:"config-show" help:"Print the effective config"`
+//	}
+//
+// Running `./myapp --verbose config-show --command agent --sources` shows
+// the global and agent settings, each annotated with its source.
+type ConfigShow struct {
+	Format  string `help:"Output format (${enum})." enum:"yaml,json,cue" default:"yaml"`
+	Command string `help:"Only show the settings seen by this command (e.g. agent.tls)." placeholder:"PATH"`
+	Sources bool   `help:"Annotate each value with where it came from."`
+
+	// Output is the writer for the config. Defaults to the Kong
+	// application's stdout. Exposed for testing; when set, the command
+	// returns instead of exiting.
+	Output io.Writer `kong:"-"`
+}
+
+// SkipsConfig reports that config-show needs the config loaded, unlike the
+// other config commands.
+func (ConfigShow) SkipsConfig() bool {
+	return false
+}
+
+// ExcludedFromSchema reports that config-show is left out of the schema,
+// since the config doesn't set its flags.
+func (ConfigShow) ExcludedFromSchema() bool {
+	return true
+}
+
+// BeforeApply prints the effective config. It runs after the config has
+// been loaded and resolved, but before validation, so required flags don't
+// need to be set.
+func (c *ConfigShow) BeforeApply(app *kong.Kong, ctx *kong.Context, config cue.Value) error {
+	src, err := ShowConfig(ctx, config, ShowOptions{
+		Command: flagString(ctx, "command", c.Command),
+		Format:  flagString(ctx, "format", c.Format),
+		Sources: flagBool(ctx, "sources", c.Sources),
+	})
+	if err != nil {
+		return err
+	}
+
+	out := c.Output
+	if out == nil {
+		out = app.Stdout
+	}
+	if _, err := out.Write(src); err != nil {
+		return err
+	}
+
+	if c.Output == nil {
+		app.Exit(0)
+	}
+	return nil
+}
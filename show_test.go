@@ -0,0 +1,203 @@
+package kongcue_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alecthomas/kong"
+	kongcue "github.com/brianm/kongcue"
+)
+
+type showCLI struct {
+	Name    string        `help:"Who to greet" default:"world"`
+	Level   string        `help:"Log level" env:"SHOW_TEST_LEVEL"`
+	Token   string        `help:"API token" secret:""`
+	Timeout time.Duration `help:"Request timeout" default:"30s"`
+	Agent   struct {
+		Port  int      `help:"Listen port" default:"8080"`
+		Hosts []string `help:"Hosts"`
+	} `cmd:""`
+	Client struct {
+		URL string `name:"url" default:"http://localhost"`
+	} `cmd:""`
+	Config     kongcue.Config     `default:"./config.yaml"`
+	ConfigShow kongcue.ConfigShow `cmd:"config-show"`
+}
+
+func runConfigShow(t *testing.T, args ...string) string {
+	t.Helper()
+	var cli showCLI
+	var buf bytes.Buffer
+	cli.ConfigShow.Output = &buf
+
+	parser, err := kong.New(&cli, kongcue.Options())
+	if err != nil {
+		t.Fatalf("failed to create parser: %v", err)
+	}
+	if _, err := parser.Parse(args); err != nil {
+		t.Fatalf("failed to parse: %v", err)
+	}
+	return buf.String()
+}
+
+func TestConfigShow(t *testing.T) {
+	dir := t.TempDir()
+	config := kongcue.WriteConfig(t, dir, "config.yaml", "token: hunter2\ntimeout: 5s\nagent:\n  hosts: [a, b]\n")
+	t.Setenv("SHOW_TEST_LEVEL", "debug")
+
+	output := runConfigShow(t, "--config", config, "--name", "cli", "config-show", "--sources")
+
+	expected := "" +
+		"name: \"cli\" # flag --name\n" +
+		"level: \"debug\" # env $SHOW_TEST_LEVEL\n" +
+		"token: \"<redacted>\" # " + config + ":1\n" +
+		"timeout: \"5s\" # " + config + ":2\n" +
+		"agent:\n" +
+		"  port: 8080 # default\n" +
+		"  hosts: [\"a\", \"b\"] # " + config + ":4\n" +
+		"client:\n" +
+		"  url: \"http://localhost\" # default\n"
+	if output != expected {
+		t.Errorf("got:\n%s\nwant:\n%s", output, expected)
+	}
+}
+
+func TestConfigShow_Command(t *testing.T) {
+	t.Chdir(t.TempDir())
+
+	output := runConfigShow(t, "config-show", "--command", "agent", "--format", "cue")
+
+	expected := "" +
+		"name: \"world\"\n" +
+		"timeout: \"30s\"\n" +
+		"agent: {\n" +
+		"\tport: 8080\n" +
+		"}\n"
+	if output != expected {
+		t.Errorf("got:\n%s\nwant:\n%s", output, expected)
+	}
+}
+
+func TestConfigShow_JSON(t *testing.T) {
+	dir := t.TempDir()
+	config := kongcue.WriteConfig(t, dir, "config.yaml", "agent:\n  port: 9090\n")
+
+	output := runConfigShow(t, "--config", config, "config-show", "--format", "json", "--sources")
+
+	var doc struct {
+		Config  map[string]any
+		Sources map[string]string
+	}
+	if err := json.Unmarshal([]byte(output), &doc); err != nil {
+		t.Fatalf("invalid JSON: %v\n%s", err, output)
+	}
+	if got := lookup(doc.Config, "agent", "port"); got != float64(9090) {
+		t.Errorf("expected agent.port 9090, got %v", got)
+	}
+	if got := doc.Sources["agent.port"]; got != filepath.Join(dir, "config.yaml")+":2" {
+		t.Errorf("unexpected source for agent.port: %q", got)
+	}
+	if got := doc.Sources["name"]; got != "default" {
+		t.Errorf("unexpected source for name: %q", got)
+	}
+}
+
+func TestShowConfig_AfterParse(t *testing.T) {
+	dir := t.TempDir()
+	config := kongcue.WriteConfig(t, dir, "config.yaml", "agent:\n  port: 9090\n")
+
+	var cli showCLI
+	parser, err := kong.New(&cli, kongcue.Options())
+	if err != nil {
+		t.Fatalf("failed to create parser: %v", err)
+	}
+	ctx, err := parser.Parse([]string{"--config", config, "agent", "--hosts", "x"})
+	if err != nil {
+		t.Fatalf("failed to parse: %v", err)
+	}
+
+	value, err := kongcue.LoadAndUnifyPaths([]string{config})
+	if err != nil {
+		t.Fatalf("failed to load config: %v", err)
+	}
+
+	src, err := kongcue.ShowConfig(ctx, value, kongcue.ShowOptions{Command: "agent", Sources: true})
+	if err != nil {
+		t.Fatalf("failed to show config: %v", err)
+	}
+	for _, want := range []string{"port: 9090 # " + config + ":2", `hosts: ["x"] # flag --hosts`} {
+		if !strings.Contains(string(src), want) {
+			t.Errorf("expected %q in:\n%s", want, src)
+		}
+	}
+}
+
+type resolvedShowCLI struct {
+	Debug bool `help:"Debug logging"`
+	Agent struct {
+		Offset int    `help:"Clock offset" default:"3"`
+		CA     string `name:"ca" help:"CA bundle" type:"path"`
+	} `cmd:""`
+	Config     kongcue.Config     `default:"./config.yaml"`
+	ConfigShow kongcue.ConfigShow `cmd:"config-show"`
+}
+
+func TestShowConfig_ResolvedValues(t *testing.T) {
+	dir := t.TempDir()
+	config := kongcue.WriteConfig(t, dir, "config.yaml", "debug: true\nagent:\n  offset: -5\n  ca: certs/ca.pem\n")
+	t.Chdir(t.TempDir())
+
+	var cli resolvedShowCLI
+	parser, err := kong.New(&cli, kongcue.Options())
+	if err != nil {
+		t.Fatalf("failed to create parser: %v", err)
+	}
+	ctx, err := parser.Parse([]string{"--config", config, "--debug=false", "agent"})
+	if err != nil {
+		t.Fatalf("failed to parse: %v", err)
+	}
+	value, err := kongcue.LoadAndUnifyPaths([]string{config})
+	if err != nil {
+		t.Fatalf("failed to load config: %v", err)
+	}
+
+	src, err := kongcue.ShowConfig(ctx, value, kongcue.ShowOptions{Sources: true})
+	if err != nil {
+		t.Fatalf("failed to show config: %v", err)
+	}
+	expected := "" +
+		"debug: false # flag --debug\n" +
+		"agent:\n" +
+		"  offset: -5 # " + config + ":3\n" +
+		"  ca: \"" + filepath.Join(dir, "certs", "ca.pem") + "\" # " + config + ":4\n"
+	if string(src) != expected {
+		t.Errorf("got:\n%s\nwant:\n%s", src, expected)
+	}
+}
+
+func TestConfigShow_UnselectedCommand(t *testing.T) {
+	dir := t.TempDir()
+	config := kongcue.WriteConfig(t, dir, "config.yaml", "agent:\n  offset: -5\n  ca: certs/ca.pem\n")
+	t.Chdir(t.TempDir())
+
+	var cli resolvedShowCLI
+	var buf bytes.Buffer
+	cli.ConfigShow.Output = &buf
+	parser, err := kong.New(&cli, kongcue.Options())
+	if err != nil {
+		t.Fatalf("failed to create parser: %v", err)
+	}
+	if _, err := parser.Parse([]string{"--config", config, "config-show", "--command", "agent"}); err != nil {
+		t.Fatalf("failed to parse: %v", err)
+	}
+
+	for _, want := range []string{"offset: -5\n", "ca: \"" + filepath.Join(dir, "certs", "ca.pem") + "\"\n"} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("expected %q in:\n%s", want, buf.String())
+		}
+	}
+}
@@ -0,0 +1,162 @@
+package kongcue
+
+import (
+	"slices"
+	"strings"
+
+	"cuelang.org/go/cue"
+	"cuelang.org/go/cue/errors"
+	"cuelang.org/go/cue/token"
+)
+
+// maxSuggestionDistance is the largest edit distance between an unknown key
+// and a valid one for the valid key to be suggested, as in Kong's own
+// "did you mean" suggestions for flags.
+const maxSuggestionDistance = 2
+
+// keyCandidate is a valid key an unknown key may be a misspelling of.
+type keyCandidate struct {
+	name    string // the key as written in configs
+	suggest string // the key to suggest instead, the new name of an old one
+}
+
+// suggestKeys returns the valid keys closest to an unknown key, or nil if
+// none are close. Keys are compared in snake_case, so kebab-case spellings
+// of a flag's name (like "ca-url" for "ca_url") are always suggested.
+func suggestKeys(key string, valid []keyCandidate) []string {
+	normalized := kebabToSnake(key)
+	best := maxSuggestionDistance + 1
+	var suggestions []string
+	for _, candidate := range valid {
+		switch d := levenshtein(normalized, candidate.name); {
+		case d < best:
+			best = d
+			suggestions = []string{candidate.suggest}
+		case d == best && !slices.Contains(suggestions, candidate.suggest):
+			suggestions = append(suggestions, candidate.suggest)
+		}
+	}
+	return suggestions
+}
+
+// didYouMean formats suggestions as a hint like Kong's, e.g.
+// "did you mean ca_url?". Returns an empty string if there are none.
+func didYouMean(suggestions []string) string {
+	switch len(suggestions) {
+	case 0:
+		return ""
+	case 1:
+		return "did you mean " + suggestions[0] + "?"
+	default:
+		return "did you mean one of " + strings.Join(suggestions, ", ") + "?"
+	}
+}
+
+// levenshtein returns the edit distance between two strings.
+func levenshtein(a, b string) int {
+	prev := make([]int, len(b)+1)
+	curr := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		curr[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			curr[j] = min(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+		prev, curr = curr, prev
+	}
+	return prev[len(b)]
+}
+
+// unknownKeyError replaces CUE's "field not allowed" error for a config
+// key that isn't in the schema, suggesting valid keys close to it. It keeps
+// the original error's path and positions.
+type unknownKeyError struct {
+	cueErr      errors.Error // the "field not allowed" error
+	suggestions []string     // valid keys at the same level close to it
+}
+
+func (e *unknownKeyError) Position() token.Pos {
+	return e.cueErr.Position()
+}
+
+func (e *unknownKeyError) InputPositions() []token.Pos {
+	return e.cueErr.InputPositions()
+}
+
+func (e *unknownKeyError) Path() []string {
+	return e.cueErr.Path()
+}
+
+func (e *unknownKeyError) Msg() (string, []any) {
+	if hint := didYouMean(e.suggestions); hint != "" {
+		return "unknown key; %s", []any{hint}
+	}
+	return "unknown key", nil
+}
+
+func (e *unknownKeyError) Error() string {
+	return errors.String(e)
+}
+
+// explainUnknownKeys replaces the "field not allowed" errors in errs with
+// unknownKeyErrors, suggesting keys from the config section at the same
+// path, or else from the fields the schema allows there. tree may be nil.
+func explainUnknownKeys(errs errors.Error, schema cue.Value, tree *configSection) errors.Error {
+	var out errors.Error
+	for _, e := range errors.Errors(errs) {
+		format, _ := e.Msg()
+		path := e.Path()
+		if format != "field not allowed" || len(path) == 0 {
+			out = errors.Append(out, e)
+			continue
+		}
+		labels := unquotedPath(path)
+		parent := labels[:len(labels)-1]
+		var valid []keyCandidate
+		if section := tree.section(parent); section != nil {
+			valid = section.keys()
+		} else {
+			// Go-typed sections are only described by the schema
+			section := schema
+			for _, label := range parent {
+				section = lookupField(section, label)
+			}
+			valid = schemaKeys(section)
+		}
+		out = errors.Append(out, &unknownKeyError{
+			cueErr:      e,
+			suggestions: suggestKeys(labels[len(labels)-1], valid),
+		})
+	}
+	return out
+}
+
+// lookupField returns a field of a struct, whether it is regular or
+// optional like the command sections of the schema.
+func lookupField(val cue.Value, name string) cue.Value {
+	if field := val.LookupPath(cue.MakePath(cue.Str(name))); field.Exists() {
+		return field
+	}
+	return val.LookupPath(cue.MakePath(cue.Str(name).Optional()))
+}
+
+// schemaKeys returns the regular field names of a schema struct.
+func schemaKeys(val cue.Value) []keyCandidate {
+	iter, err := val.Fields(cue.Optional(true))
+	if err != nil {
+		return nil
+	}
+	var keys []keyCandidate
+	for iter.Next() {
+		if sel := iter.Selector(); sel.IsString() {
+			keys = append(keys, keyCandidate{name: sel.Unquoted(), suggest: sel.Unquoted()})
+		}
+	}
+	return keys
+}
@@ -0,0 +1,78 @@
+package kongcue_test
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/alecthomas/kong"
+	kongcue "github.com/brianm/kongcue"
+)
+
+type suggestCLI struct {
+	Config  kongcue.Config `default:"./config.yaml"`
+	Verbose bool           `name:"verbose"`
+	Agent   struct {
+		CaURL  string `name:"ca-url"`
+		CaFile string `name:"ca-file"`
+		Host   string `name:"host"`
+		Port   int    `name:"port"`
+	} `cmd:""`
+}
+
+func TestUnknownKey_Suggestions(t *testing.T) {
+	tests := []struct {
+		config, want string
+	}{
+		{"agent:\n  ca-ur: x\n", `agent.ca-ur: unknown key; did you mean ca_url?`},
+		// Kebab-case spellings of a flag are always suggested
+		{"agent:\n  ca-url: x\n", `agent.ca-url: unknown key; did you mean ca_url?`},
+		{"agent:\n  ca_fil: x\n", "agent.ca_fil: unknown key; did you mean ca_file?"},
+		{"agent:\n  hort: x\n", "agent.hort: unknown key; did you mean one of host, port?"},
+		{"verbos: true\n", "verbos: unknown key; did you mean verbose?"},
+		{"agnt:\n  ca_url: x\n", "agnt: unknown key; did you mean agent?"},
+	}
+	for _, tt := range tests {
+		_, err := kongcue.ParseWithConfig(t, &suggestCLI{}, tt.config, []kong.Option{kongcue.Options()}, "agent")
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("config %q: expected %q, got %v", tt.config, tt.want, err)
+		}
+		if err != nil && strings.Contains(err.Error(), "Hint:") {
+			t.Errorf("config %q: generic hint should be left out with a suggestion:\n%v", tt.config, err)
+		}
+	}
+}
+
+func TestUnknownKey_Position(t *testing.T) {
+	_, err := kongcue.ParseWithConfig(t, &suggestCLI{}, "verbose: true\nagent:\n  ca-ur: x\n", []kong.Option{kongcue.Options()}, "agent")
+	if err == nil || !strings.Contains(err.Error(), "/config.yaml:3:3") {
+		t.Errorf("expected the error to be positioned at the key, got %v", err)
+	}
+}
+
+func TestUnknownKey_NoSuggestion(t *testing.T) {
+	_, err := kongcue.ParseWithConfig(t, &suggestCLI{}, "agent:\n  timeout: 5\n", []kong.Option{kongcue.Options()}, "agent")
+	if err == nil {
+		t.Fatal("expected an unknown key error")
+	}
+	if msg := err.Error(); !strings.Contains(msg, "agent.timeout: unknown key") || strings.Contains(msg, "did you mean") {
+		t.Errorf("expected no suggestion, got %v", err)
+	}
+	if !strings.Contains(err.Error(), "Hint: Check that all config keys correspond to valid CLI flags") {
+		t.Errorf("expected the generic hint, got %v", err)
+	}
+}
+
+func TestSetConfigValue_SuggestsKeys(t *testing.T) {
+	var cli suggestCLI
+	parser, err := kong.New(&cli, kongcue.Options())
+	if err != nil {
+		t.Fatalf("failed to create parser: %v", err)
+	}
+	path := filepath.Join(t.TempDir(), "config.yaml")
+
+	err = kongcue.SetConfigValue(parser.Model, path, "agent.ca-url", "x", nil)
+	if err == nil || err.Error() != `unknown config key "agent.ca-url"; did you mean ca_url?` {
+		t.Errorf("expected a suggestion, got %v", err)
+	}
+}
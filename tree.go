@@ -0,0 +1,203 @@
+package kongcue
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/alecthomas/kong"
+)
+
+// configSection is one level of a config file: the flags of a command (or
+// of the application for the root section) and the sections of its
+// subcommands. It mirrors the structure of the generated CUE schema and is
+// used by the non-CUE renderers.
+type configSection struct {
+	node     *kong.Node
+	path     []string     // command names from the root, empty for the root section
+	flags    []*kong.Flag // flags settable from config at this level
+	children []*configSection
+	sections []string // keys of Go-typed sections registered with Section
+	extra    []string // allowed unknown keys at this level that aren't flags or commands
+	open     bool     // unknown keys are allowed at this level
+	opts     *schemaOptions
+}
+
+// buildConfigTree walks the Kong model and returns the root config section.
+func buildConfigTree(app *kong.Application, opts *schemaOptions) *configSection {
+	if opts == nil {
+		opts = &schemaOptions{}
+	}
+	return buildSection(app.Node, nil, opts)
+}
+
+// buildSection builds the config section for a node and its subcommands.
+func buildSection(node *kong.Node, path []string, opts *schemaOptions) *configSection {
+	section := &configSection{
+		node: node,
+		path: path,
+		open: opts.shouldAllowUnknown(strings.Join(path, ".")),
+		opts: opts,
+	}
+
+	existing := make(map[string]bool)
+	for _, flag := range node.Flags {
+		if !isConfigFlag(flag) {
+			continue
+		}
+		section.flags = append(section.flags, flag)
+		existing[kebabToSnake(flag.Name)] = true
+	}
+
+	for _, child := range node.Children {
+		if child.Type != kong.CommandNode || excludedFromSchema(child) {
+			continue
+		}
+		childPath := append(append([]string{}, path...), child.Name)
+		section.children = append(section.children, buildSection(child, childPath, opts))
+		existing[child.Name] = true
+	}
+
+	dotPath := strings.Join(path, ".")
+	for _, key := range opts.sectionsAt(dotPath) {
+		if !existing[key] {
+			existing[key] = true
+			section.sections = append(section.sections, key)
+		}
+	}
+	for _, allowed := range opts.allowUnknownPaths {
+		fieldName := opts.getAllowedFieldAtPath(allowed, dotPath)
+		if fieldName != "" && !existing[fieldName] {
+			existing[fieldName] = true
+			section.extra = append(section.extra, fieldName)
+		}
+	}
+
+	return section
+}
+
+// keys returns the keys to suggest for a config section: its flags,
+// subcommand sections, Go-typed sections and allowed unknown keys, and
+// config_version at the root of versioned configs. Keys of deprecated flags
+// are left out, and the old names of renamed flags suggest the new name.
+func (s *configSection) keys() []keyCandidate {
+	var keys []keyCandidate
+	add := func(name, suggest string) {
+		keys = append(keys, keyCandidate{name: name, suggest: suggest})
+	}
+	for _, flag := range s.flags {
+		if flag.Tag.Has(deprecatedTag) {
+			continue
+		}
+		key := kebabToSnake(flag.Name)
+		add(key, key)
+		for _, alias := range flag.Aliases {
+			add(kebabToSnake(alias), key)
+		}
+	}
+	for _, child := range s.children {
+		add(child.path[len(child.path)-1], child.path[len(child.path)-1])
+	}
+	for _, key := range append(slices.Clone(s.sections), s.extra...) {
+		add(key, key)
+	}
+	if len(s.path) == 0 && s.opts.latestConfigVersion() > 0 {
+		add(versionKey, versionKey)
+	}
+	return keys
+}
+
+// section returns the section at a path of command names below s, or nil
+// if there is none.
+func (s *configSection) section(path []string) *configSection {
+	for _, name := range path {
+		if s == nil {
+			return nil
+		}
+		s = s.child(name)
+	}
+	return s
+}
+
+// lookup returns the deepest section on a config path, and the flag set
+// by the path's last key in that section, also matching the old names from
+// its aliases. The flag is nil if the key doesn't set one. The section may
+// be nil, in which case both results are nil.
+func (s *configSection) lookup(path []string) (*configSection, *kong.Flag) {
+	if s == nil {
+		return nil, nil
+	}
+	section := s
+	for i, name := range path {
+		if next := section.child(name); next != nil {
+			section = next
+			continue
+		}
+		if i < len(path)-1 {
+			return section, nil
+		}
+		for _, flag := range section.flags {
+			if kebabToSnake(flag.Name) == name {
+				return section, flag
+			}
+			for _, alias := range flag.Aliases {
+				if kebabToSnake(alias) == name {
+					return section, flag
+				}
+			}
+		}
+	}
+	return section, nil
+}
+
+// child returns the section of the subcommand with the given name, or nil.
+func (s *configSection) child(name string) *configSection {
+	for _, child := range s.children {
+		if child.path[len(child.path)-1] == name {
+			return child
+		}
+	}
+	return nil
+}
+
+// command returns the section's command as typed on the command line,
+// e.g. "server tls", or "" for the root section.
+func (s *configSection) command() string {
+	return strings.Join(s.path, " ")
+}
+
+// dotPath returns the section's config path, e.g. "server.tls".
+func (s *configSection) dotPath() string {
+	return strings.Join(s.path, ".")
+}
+
+// find returns the section at the given dot-separated command path.
+// An empty path returns the section itself.
+func (s *configSection) find(path string) (*configSection, error) {
+	if path == "" {
+		return s, nil
+	}
+	current := s
+	for _, name := range strings.Split(path, ".") {
+		var next *configSection
+		for _, child := range current.children {
+			if child.path[len(child.path)-1] == name {
+				next = child
+				break
+			}
+		}
+		if next == nil {
+			return nil, fmt.Errorf("unknown command %q", path)
+		}
+		current = next
+	}
+	return current, nil
+}
+
+// walk calls fn for the section and all its descendants, depth first.
+func (s *configSection) walk(fn func(*configSection)) {
+	fn(s)
+	for _, child := range s.children {
+		child.walk(fn)
+	}
+}
@@ -0,0 +1,217 @@
+package kongcue
+
+import (
+	"fmt"
+	"maps"
+	"net"
+	"net/url"
+	"reflect"
+	"strconv"
+	"sync"
+	"time"
+
+	"cuelang.org/go/cue"
+	"cuelang.org/go/cue/ast"
+	"cuelang.org/go/cue/parser"
+	"github.com/alecthomas/kong"
+)
+
+// TypeMapping describes how values of a Go type are written in config files.
+type TypeMapping struct {
+	// Schema is a CUE expression that config values must satisfy,
+	// e.g. `string & =~"^[a-z]+$"` or `int | string`.
+	Schema string
+
+	// Extract converts a config value into a value Kong can decode for the flag.
+	// If nil, the value is extracted like any other scalar (string, int, bool).
+	Extract func(val cue.Value) (any, error)
+
+	// Encode converts a Go value of the type into a config value that
+	// Extract and Kong's mapper accept, for writing flags back to config
+	// files and command lines. format is the flag's format:"..." tag. If
+	// nil, values are written with their MarshalText or String method, or
+	// as the scalar they're made of.
+	Encode func(v any, format string) any
+
+	// JSONSchema is the equivalent JSON Schema used by GenerateJSONSchema,
+	// e.g. {"type": "string", "format": "uri"}. If nil, any value is allowed.
+	JSONSchema map[string]any
+}
+
+var (
+	typeMappingsMu sync.RWMutex
+	typeMappings   = map[reflect.Type]TypeMapping{}
+)
+
+// RegisterType registers how values of a Go type appear in config files.
+// The mapping is used both when generating the schema and when resolving
+// flag values from config. Pointer types share the mapping of their element
+// type, so registering url.URL also covers *url.URL.
+//
+// RegisterType is intended to be called from init functions and panics if
+// the schema is not a valid CUE expression. Use WithType to set a mapping
+// for one application only.
+//
+// Usage:
+//
+//	kongcue.RegisterType(reflect.TypeFor[LogLevel](), kongcue.TypeMapping{
+//	    Schema: `"debug" | "info" | "warn" | "error"`,
+//	})
+func RegisterType(typ reflect.Type, mapping TypeMapping) {
+	if _, err := parser.ParseExpr(typ.String(), mapping.Schema); err != nil {
+		panic(fmt.Sprintf("kongcue: invalid schema for %s: %v", typ, err))
+	}
+	for typ.Kind() == reflect.Pointer {
+		typ = typ.Elem()
+	}
+	typeMappingsMu.Lock()
+	defer typeMappingsMu.Unlock()
+	typeMappings[typ] = mapping
+}
+
+// WithType returns a Kong option that sets how values of a Go type appear
+// in config files like RegisterType, but only for the application it's
+// passed to. It takes precedence over a mapping registered with
+// RegisterType. Creating the parser fails if the schema is not a valid CUE
+// expression.
+//
+//	kong.Parse(&cli, kongcue.WithType(reflect.TypeFor[LogLevel](), kongcue.TypeMapping{
+//	    Schema: `"debug" | "info" | "warn" | "error"`,
+//	}))
+func WithType(typ reflect.Type, mapping TypeMapping) kong.Option {
+	return schemaOption(func(opts *SchemaOptions) error {
+		if _, err := parser.ParseExpr(typ.String(), mapping.Schema); err != nil {
+			return fmt.Errorf("kongcue: invalid schema for %s: %w", typ, err)
+		}
+		for typ.Kind() == reflect.Pointer {
+			typ = typ.Elem()
+		}
+		if opts.types == nil {
+			opts.types = map[reflect.Type]TypeMapping{}
+		}
+		opts.types[typ] = mapping
+		return nil
+	})
+}
+
+// typeMapping returns the mapping for a Go type, if any: the one added
+// with WithType, or else the one registered with RegisterType.
+func (opts *schemaOptions) typeMapping(typ reflect.Type) (TypeMapping, bool) {
+	for typ.Kind() == reflect.Pointer {
+		typ = typ.Elem()
+	}
+	if opts != nil {
+		if m, ok := opts.types[typ]; ok {
+			return m, true
+		}
+	}
+	typeMappingsMu.RLock()
+	defer typeMappingsMu.RUnlock()
+	m, ok := typeMappings[typ]
+	return m, ok
+}
+
+// schema parses the mapping's CUE expression into a fresh AST node.
+func (m TypeMapping) schema() ast.Expr {
+	expr, err := parser.ParseExpr("type mapping", m.Schema)
+	if err != nil {
+		// Validated in RegisterType and WithType
+		return ast.NewIdent("_")
+	}
+	return expr
+}
+
+// jsonSchema returns a copy of the mapping's JSON Schema that callers may
+// add keywords to.
+func (m TypeMapping) jsonSchema() map[string]any {
+	return maps.Clone(m.JSONSchema)
+}
+
+// goTypeToType converts a Go type to a CUE type expression, preferring
+// type mappings over the type's kind.
+func goTypeToType(typ reflect.Type, opts *schemaOptions) ast.Expr {
+	if m, ok := opts.typeMapping(typ); ok {
+		return m.schema()
+	}
+	return kindToType(typ.Kind())
+}
+
+// Regular expressions for the built-in type mappings.
+const (
+	durationPattern = `^[-+]?(0|([0-9]*(\.[0-9]*)?(ns|us|µs|μs|ms|s|m|h))+)$`
+	urlPattern      = `^[A-Za-z][A-Za-z0-9+.-]*:`
+	ipPattern       = `^([0-9]{1,3}(\.[0-9]{1,3}){3}|[0-9A-Fa-f:.]*:[0-9A-Fa-f:.]*)$`
+)
+
+// extractDuration normalises integer nanoseconds to a duration string, so
+// durations survive being joined into slice flag values.
+func extractDuration(val cue.Value) (any, error) {
+	if val.Kind() == cue.IntKind {
+		ns, err := val.Int64()
+		if err != nil {
+			return nil, err
+		}
+		return time.Duration(ns).String(), nil
+	}
+	return val.String()
+}
+
+// encodeStringer writes a value with its String method.
+func encodeStringer(v any, _ string) any {
+	return v.(fmt.Stringer).String()
+}
+
+// encodeTime writes a time in the flag's format, as Kong's time mapper
+// parses it.
+func encodeTime(v any, format string) any {
+	if format == "" {
+		format = time.RFC3339
+	}
+	return v.(time.Time).Format(format)
+}
+
+// encodeURL writes a URL in its string form. url.URL only has String on
+// its pointer.
+func encodeURL(v any, _ string) any {
+	u := v.(url.URL)
+	return u.String()
+}
+
+func init() {
+	// Durations are accepted as Go duration strings ("1m30s") or integer nanoseconds,
+	// matching what Kong's duration mapper accepts.
+	RegisterType(reflect.TypeFor[time.Duration](), TypeMapping{
+		Schema:  `int | string & =~` + strconv.Quote(durationPattern),
+		Extract: extractDuration,
+		Encode:  encodeStringer,
+		JSONSchema: map[string]any{
+			"type":    []any{"string", "integer"},
+			"pattern": durationPattern,
+		},
+	})
+	// Times are strings in the flag's format (RFC 3339 unless format:"..." is set).
+	RegisterType(reflect.TypeFor[time.Time](), TypeMapping{
+		Schema:     `string`,
+		Encode:     encodeTime,
+		JSONSchema: map[string]any{"type": "string"},
+	})
+	// URLs must be absolute, i.e. start with a scheme.
+	RegisterType(reflect.TypeFor[url.URL](), TypeMapping{
+		Schema: `string & =~` + strconv.Quote(urlPattern),
+		Encode: encodeURL,
+		JSONSchema: map[string]any{
+			"type":    "string",
+			"format":  "uri",
+			"pattern": urlPattern,
+		},
+	})
+	// IPs are IPv4 dotted quads or IPv6 addresses.
+	RegisterType(reflect.TypeFor[net.IP](), TypeMapping{
+		Schema: `string & =~` + strconv.Quote(ipPattern),
+		Encode: encodeStringer,
+		JSONSchema: map[string]any{
+			"type":    "string",
+			"pattern": ipPattern,
+		},
+	})
+}
@@ -0,0 +1,276 @@
+package kongcue_test
+
+import (
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"cuelang.org/go/cue"
+	"github.com/alecthomas/kong"
+	kongcue "github.com/brianm/kongcue"
+)
+
+type wellKnownCLI struct {
+	Timeout  time.Duration   `name:"timeout"`
+	Retries  []time.Duration `name:"retries"`
+	Since    time.Time       `name:"since"`
+	Endpoint *url.URL        `name:"endpoint"`
+	Bind     net.IP          `name:"bind"`
+	Config   kongcue.Config  `name:"config"`
+}
+
+func TestWellKnownTypes_Resolve(t *testing.T) {
+	dir := t.TempDir()
+	configFile := filepath.Join(dir, "config.yaml")
+	if err := os.WriteFile(configFile, []byte(`
+timeout: 1m30s
+since: "2024-05-01T12:00:00Z"
+endpoint: "https://api.example.com/v1"
+bind: "10.0.0.1"
+`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	var cli wellKnownCLI
+	parser, err := kong.New(&cli, kongcue.Options())
+	if err != nil {
+		t.Fatalf("failed to create parser: %v", err)
+	}
+	if _, err := parser.Parse([]string{"--config", configFile}); err != nil {
+		t.Fatalf("valid config should not produce error: %v", err)
+	}
+
+	if cli.Timeout != 90*time.Second {
+		t.Errorf("expected timeout 1m30s, got %s", cli.Timeout)
+	}
+	if !cli.Since.Equal(time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)) {
+		t.Errorf("unexpected since: %s", cli.Since)
+	}
+	if cli.Endpoint == nil || cli.Endpoint.Host != "api.example.com" {
+		t.Errorf("unexpected endpoint: %v", cli.Endpoint)
+	}
+	if !cli.Bind.Equal(net.ParseIP("10.0.0.1")) {
+		t.Errorf("unexpected bind: %v", cli.Bind)
+	}
+}
+
+func TestWellKnownTypes_DurationNanoseconds(t *testing.T) {
+	dir := t.TempDir()
+	configFile := filepath.Join(dir, "config.yaml")
+	if err := os.WriteFile(configFile, []byte("timeout: 1500000000"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	var cli wellKnownCLI
+	parser, err := kong.New(&cli, kongcue.Options())
+	if err != nil {
+		t.Fatalf("failed to create parser: %v", err)
+	}
+	if _, err := parser.Parse([]string{"--config", configFile}); err != nil {
+		t.Fatalf("integer nanoseconds should be accepted: %v", err)
+	}
+	if cli.Timeout != 1500*time.Millisecond {
+		t.Errorf("expected timeout 1.5s, got %s", cli.Timeout)
+	}
+}
+
+func TestWellKnownTypes_MapValues(t *testing.T) {
+	dir := t.TempDir()
+	configFile := filepath.Join(dir, "config.yaml")
+	if err := os.WriteFile(configFile, []byte("timeouts: {a: 1500000000, b: 2s}"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	var cli struct {
+		Timeouts map[string]time.Duration `name:"timeouts"`
+		Config   kongcue.Config           `name:"config"`
+	}
+	parser, err := kong.New(&cli, kongcue.Options())
+	if err != nil {
+		t.Fatalf("failed to create parser: %v", err)
+	}
+	if _, err := parser.Parse([]string{"--config", configFile}); err != nil {
+		t.Fatalf("map values should be extracted like the type: %v", err)
+	}
+	if cli.Timeouts["a"] != 1500*time.Millisecond || cli.Timeouts["b"] != 2*time.Second {
+		t.Errorf("unexpected timeouts %v", cli.Timeouts)
+	}
+}
+
+func TestWellKnownTypes_Schema(t *testing.T) {
+	var cli wellKnownCLI
+	parser, err := kong.New(&cli)
+	if err != nil {
+		t.Fatalf("failed to create parser: %v", err)
+	}
+
+	config, _ := kongcue.LoadAndUnifyPaths([]string{})
+	schema, err := kongcue.GenerateSchema(config.Context(), parser.Model, nil)
+	if err != nil {
+		t.Fatalf("failed to generate schema: %v", err)
+	}
+
+	tests := []struct {
+		config string
+		valid  bool
+	}{
+		{`timeout: "250ms"`, true},
+		{`timeout: "1h2m3.5s"`, true},
+		{`timeout: 1000`, true},
+		{`timeout: "soon"`, false},
+		{`retries: ["1s", 2000]`, true},
+		{`retries: ["1s", "later"]`, false},
+		{`since: "2024-05-01T12:00:00Z"`, true},
+		{`since: 1714564800`, false},
+		{`endpoint: "https://example.com"`, true},
+		{`endpoint: "example.com/path"`, false},
+		{`bind: "192.168.1.10"`, true},
+		{`bind: "::1"`, true},
+		{`bind: "localhost"`, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.config, func(t *testing.T) {
+			val := config.Context().CompileString(tt.config)
+			err := kongcue.ValidateConfig(schema, val)
+			if tt.valid && err != nil {
+				t.Errorf("expected %q to be valid: %v", tt.config, err)
+			}
+			if !tt.valid && err == nil {
+				t.Errorf("expected %q to be rejected", tt.config)
+			}
+		})
+	}
+}
+
+// shade is a custom type registered with a schema and an extraction hook.
+type shade string
+
+func init() {
+	kongcue.RegisterType(reflect.TypeFor[shade](), kongcue.TypeMapping{
+		Schema: `"light" | "dark" | bool`,
+		Extract: func(val cue.Value) (any, error) {
+			if b, err := val.Bool(); err == nil {
+				if b {
+					return "dark", nil
+				}
+				return "light", nil
+			}
+			return val.String()
+		},
+	})
+}
+
+func TestRegisterType_Custom(t *testing.T) {
+	dir := t.TempDir()
+	configFile := filepath.Join(dir, "config.yaml")
+	if err := os.WriteFile(configFile, []byte("theme: true"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	var cli struct {
+		Theme  shade          `name:"theme" default:"light"`
+		Config kongcue.Config `name:"config"`
+	}
+	parser, err := kong.New(&cli, kongcue.Options())
+	if err != nil {
+		t.Fatalf("failed to create parser: %v", err)
+	}
+	if _, err := parser.Parse([]string{"--config", configFile}); err != nil {
+		t.Fatalf("valid config should not produce error: %v", err)
+	}
+	if cli.Theme != "dark" {
+		t.Errorf("expected theme dark, got %q", cli.Theme)
+	}
+
+	if err := os.WriteFile(configFile, []byte("theme: blue"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	_, err = parser.Parse([]string{"--config", configFile})
+	if err == nil || !strings.Contains(err.Error(), "theme") {
+		t.Errorf("expected schema violation for theme, got: %v", err)
+	}
+}
+
+func TestRegisterType_InvalidSchemaPanics(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("expected panic for invalid schema")
+		}
+	}()
+	kongcue.RegisterType(reflect.TypeFor[struct{ X int }](), kongcue.TypeMapping{Schema: "int &"})
+}
+
+type priority string
+
+func TestWithType(t *testing.T) {
+	dir := t.TempDir()
+	configFile := filepath.Join(dir, "config.yaml")
+	if err := os.WriteFile(configFile, []byte("priority: 2"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	type priorityCLI struct {
+		Priority priority       `name:"priority"`
+		Config   kongcue.Config `name:"config"`
+	}
+	names := []string{"low", "normal", "high"}
+	withPriority := kongcue.WithType(reflect.TypeFor[priority](), kongcue.TypeMapping{
+		Schema: "0 | 1 | 2",
+		Extract: func(val cue.Value) (any, error) {
+			i, err := val.Int64()
+			if err != nil {
+				return nil, err
+			}
+			return names[i], nil
+		},
+	})
+
+	var cli priorityCLI
+	parser, err := kong.New(&cli, withPriority)
+	if err != nil {
+		t.Fatalf("failed to create parser: %v", err)
+	}
+	ctx, err := parser.Parse([]string{"--config", configFile})
+	if err != nil {
+		t.Fatalf("valid config should not produce error: %v", err)
+	}
+	if cli.Priority != "high" {
+		t.Errorf("expected priority high, got %q", cli.Priority)
+	}
+	config, err := kongcue.LoadAndUnifyPaths([]string{configFile})
+	if err != nil {
+		t.Fatalf("failed to load config: %v", err)
+	}
+	args, err := kongcue.CommandLine(ctx, config)
+	if err != nil {
+		t.Fatalf("failed to build command line: %v", err)
+	}
+	if got := strings.Join(args, " "); !strings.Contains(got, "--priority=high") {
+		t.Errorf("expected the resolved priority in %q", got)
+	}
+	// Other applications don't see the mapping
+	var other priorityCLI
+	parser, err = kong.New(&other, kongcue.Options())
+	if err != nil {
+		t.Fatalf("failed to create parser: %v", err)
+	}
+	if _, err := parser.Parse([]string{"--config", configFile}); err == nil {
+		t.Error("expected a type mismatch without the mapping")
+	}
+}
+
+func TestWithType_InvalidSchema(t *testing.T) {
+	var cli struct {
+		Config kongcue.Config `name:"config"`
+	}
+	_, err := kong.New(&cli, kongcue.WithType(reflect.TypeFor[priority](), kongcue.TypeMapping{Schema: "int &"}))
+	if err == nil || !strings.Contains(err.Error(), "invalid schema") {
+		t.Errorf("expected an invalid schema error, got %v", err)
+	}
+}
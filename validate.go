@@ -0,0 +1,217 @@
+package kongcue
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"cuelang.org/go/cue/errors"
+	"cuelang.org/go/cue/token"
+	"github.com/alecthomas/kong"
+	"github.com/bmatcuk/doublestar/v4"
+)
+
+// Validate loads config files and checks them against the schema generated
+// from a Kong application model, without parsing a command line or running
+// a command. Files are loaded and validated with the same rules kongcue.Config
+// uses; opts may be nil to reject unknown fields everywhere.
+//
+// Unlike Config, a path or glob pattern that matches no files is an error,
+// so a typo doesn't make a lint step pass.
+//
+// Example:
+//
+//	parser := kong.Must(&cli)
+//	warnings, err := kongcue.Validate(parser.Model, []string{"deploy/*.yaml"}, nil)
+//	if err != nil {
+//	    log.Fatal(err)
+//	}
+//	for _, w := range warnings {
+//	    log.Print(w)
+//	}
+//
+// Warnings, such as deprecated keys, are returned for the caller to report;
+// opts.OnWarning isn't called. With opts.StrictWarnings they make Validate
+// return an error instead.
+func Validate(app *kong.Application, paths []string, opts *SchemaOptions) ([]Warning, error) {
+	internal := opts.toInternal()
+	warnings, err := validatePaths(app, paths, internal)
+	if err != nil {
+		return nil, newValidationError(app, internal, err)
+	}
+	if len(warnings) > 0 && opts != nil && opts.StrictWarnings {
+		return nil, warningsError(warnings)
+	}
+	return warnings, nil
+}
+
+// validatePaths loads and validates config files, returning all problems
+// found as CUE errors so they keep their source positions, along with any
+// warnings.
+func validatePaths(app *kong.Application, paths []string, opts *schemaOptions) ([]Warning, error) {
+	var allErrs errors.Error
+	expanded := make([]string, len(paths))
+	for i, path := range paths {
+		expanded[i] = kong.ExpandPath(path)
+		if matches, _ := doublestar.FilepathGlob(expanded[i]); len(matches) == 0 {
+			allErrs = errors.Append(allErrs, errors.Newf(token.NoPos, "no config files match %s", path))
+		}
+	}
+	if allErrs != nil {
+		return nil, allErrs
+	}
+
+	loaded, err := loadConfig(expanded, opts)
+	if err != nil {
+		return nil, err
+	}
+	val := loaded.Value
+	if iter, _ := val.Fields(); !iter.Next() {
+		return nil, nil // Empty configs are accepted, as with Config
+	}
+
+	schema, permissive, err := validationSchemas(val.Context(), app, opts)
+	if err != nil {
+		return nil, err
+	}
+	warnings := deprecationWarnings(app, val)
+	if _, errs := checkConfig(val, schema, permissive, buildConfigTree(app, opts)); errs != nil {
+		return warnings, errs
+	}
+	return warnings, nil
+}
+
+// ConfigValidate is a Kong command that checks config files against the
+// CLI's schema without running anything else, for linting configs in CI.
+// Embed this in your CLI struct alongside Config.
+//
+// The files to check are given as arguments; without arguments, the files
+// named by the Config flag are checked. Every problem is printed with its
+// file and line, as text or with --json as a JSON document, and the command
+// exits with status 1 if any are found. Warnings, such as deprecated keys,
+// are printed too, and only fail the check with SchemaOptions.StrictWarnings.
+//
+// Usage:
+//
+//	type cli struct {
+//	    Config         kongcue.Config         `default:"~/.myapp.yaml"`
+//	    ConfigValidate kongcue.ConfigValidate `cmd:"config-validate" help:"Check config files"`
+//	}
+//
+// Running `./myapp config-validate deploy/*.yaml` checks the deploy configs.
+type ConfigValidate struct {
+	Paths []string `arg:"" optional:"" help:"Config files or glob patterns to check. Defaults to the --config files." placeholder:"FILE"`
+	JSON  bool     `name:"json" help:"Print the result as JSON."`
+
+	// Output is the writer for the result. Defaults to the Kong
+	// application's stdout. Exposed for testing; when set, the command
+	// returns instead of exiting.
+	Output io.Writer `kong:"-"`
+}
+
+// SkipsConfig reports that the config isn't loaded for config-validate,
+// which reports errors in it itself.
+func (ConfigValidate) SkipsConfig() bool {
+	return true
+}
+
+// validateResult is the JSON document printed by ConfigValidate --json.
+type validateResult struct {
+	Valid    bool      `json:"valid"`
+	Files    []string  `json:"files"`
+	Errors   []Issue   `json:"errors"`
+	Warnings []Warning `json:"warnings,omitempty"`
+}
+
+// BeforeApply validates the config files. Like ConfigDoc, it runs before
+// validation of the command line so required flags don't need to be set.
+// Invalid configs make it return an error (after printing the problems)
+// when Output is set, and exit with status 1 otherwise.
+func (c *ConfigValidate) BeforeApply(app *kong.Kong, ctx *kong.Context, schemaOpts *SchemaOptions) error {
+	paths := positionalStrings(ctx, "paths")
+	if len(paths) == 0 {
+		paths = configFlagPaths(ctx)
+	}
+	if len(paths) == 0 {
+		return fmt.Errorf("no config files to validate")
+	}
+	jsonOut := flagBool(ctx, "json", c.JSON)
+
+	opts := schemaOpts.toInternal()
+	warnings, err := validatePaths(app.Model, paths, opts)
+	issues := configIssues(app.Model, opts, err)
+	if schemaOpts != nil && schemaOpts.StrictWarnings {
+		for _, w := range warnings {
+			issues = append(issues, Issue(w))
+		}
+		warnings = nil
+	}
+
+	out := c.Output
+	if out == nil {
+		out = app.Stdout
+	}
+	if jsonOut {
+		result := validateResult{Valid: len(issues) == 0, Files: paths, Errors: issues, Warnings: warnings}
+		if result.Errors == nil {
+			result.Errors = []Issue{}
+		}
+		enc := json.NewEncoder(out)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(result); err != nil {
+			return err
+		}
+	} else {
+		for _, issue := range issues {
+			fmt.Fprintln(out, issue)
+		}
+		for _, w := range warnings {
+			fmt.Fprintf(out, "warning: %s\n", w)
+		}
+		if len(issues) == 0 {
+			fmt.Fprintf(out, "%s: ok\n", strings.Join(paths, ", "))
+		}
+	}
+
+	if c.Output != nil {
+		if len(issues) > 0 {
+			return fmt.Errorf("config validation failed with %d error(s)", len(issues))
+		}
+		return nil
+	}
+	if len(issues) > 0 {
+		app.Exit(1)
+	} else {
+		app.Exit(0)
+	}
+	return nil
+}
+
+// positionalStrings returns the values of the selected command's string
+// slice positional argument with the given name from the parse context.
+func positionalStrings(ctx *kong.Context, name string) []string {
+	v, _ := positionalValue(ctx, name).([]string)
+	return v
+}
+
+// positionalValue returns the parsed value of the selected command's
+// positional argument with the given name, or nil if it wasn't given.
+func positionalValue(ctx *kong.Context, name string) any {
+	for _, trace := range ctx.Path {
+		if trace.Positional != nil && trace.Positional.Name == name {
+			return ctx.Value(trace).Interface()
+		}
+	}
+	return nil
+}
+
+// configFlagPaths returns the paths of the application's Config flag.
+func configFlagPaths(ctx *kong.Context) []string {
+	for _, flag := range ctx.Flags() {
+		if paths, ok := ctx.FlagValue(flag).(Config); ok {
+			return paths
+		}
+	}
+	return nil
+}
@@ -0,0 +1,185 @@
+package kongcue_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/alecthomas/kong"
+	kongcue "github.com/brianm/kongcue"
+)
+
+type validateCLI struct {
+	Name  string `help:"Who to greet"`
+	Agent struct {
+		Port int `help:"Listen port"`
+	} `cmd:""`
+	Config         kongcue.Config         `default:"./config.yaml"`
+	ConfigValidate kongcue.ConfigValidate `cmd:"config-validate"`
+}
+
+func TestValidate(t *testing.T) {
+	dir := t.TempDir()
+	good := kongcue.WriteConfig(t, dir, "good.yaml", "name: world\nagent:\n  port: 8080\n")
+	bad := kongcue.WriteConfig(t, dir, "bad.yaml", "name: world\nagent:\n  port: eighty\n  host: x\n")
+
+	var cli validateCLI
+	parser, err := kong.New(&cli, kongcue.Options())
+	if err != nil {
+		t.Fatalf("failed to create parser: %v", err)
+	}
+
+	if _, err := kongcue.Validate(parser.Model, []string{good}, nil); err != nil {
+		t.Errorf("expected valid config, got: %v", err)
+	}
+
+	_, err = kongcue.Validate(parser.Model, []string{bad}, nil)
+	if err == nil {
+		t.Fatal("expected validation error")
+	}
+	for _, want := range []string{"agent.port", "agent.host", "bad.yaml:3"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("expected error to mention %q, got: %v", want, err)
+		}
+	}
+
+	_, err = kongcue.Validate(parser.Model, []string{filepath.Join(dir, "missing-*.yaml")}, nil)
+	if err == nil || !strings.Contains(err.Error(), "no config files match") {
+		t.Errorf("expected error for pattern without matches, got: %v", err)
+	}
+}
+
+func TestValidate_AllowUnknownFields(t *testing.T) {
+	dir := t.TempDir()
+	path := kongcue.WriteConfig(t, dir, "config.yaml", "name: world\nextra:\n  anything: 1\n")
+
+	var cli validateCLI
+	parser, err := kong.New(&cli)
+	if err != nil {
+		t.Fatalf("failed to create parser: %v", err)
+	}
+
+	if _, err := kongcue.Validate(parser.Model, []string{path}, nil); err == nil {
+		t.Error("expected unknown field error")
+	}
+	opts := &kongcue.SchemaOptions{AllowUnknownPaths: []string{"extra"}}
+	if _, err := kongcue.Validate(parser.Model, []string{path}, opts); err != nil {
+		t.Errorf("expected extra to be allowed, got: %v", err)
+	}
+}
+
+func TestConfigValidate_Text(t *testing.T) {
+	dir := t.TempDir()
+	bad := kongcue.WriteConfig(t, dir, "bad.yaml", "name: world\nagent:\n  port: eighty\n")
+
+	var cli validateCLI
+	var buf bytes.Buffer
+	cli.ConfigValidate.Output = &buf
+
+	parser, err := kong.New(&cli, kongcue.Options())
+	if err != nil {
+		t.Fatalf("failed to create parser: %v", err)
+	}
+	if _, err := parser.Parse([]string{"config-validate", bad}); err == nil {
+		t.Fatal("expected validation failure")
+	}
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 1 {
+		t.Fatalf("expected one error line, got:\n%s", buf.String())
+	}
+	if want := bad + ":3:9: agent.port: "; !strings.HasPrefix(lines[0], want) {
+		t.Errorf("expected line to start with %q, got %q", want, lines[0])
+	}
+}
+
+func TestConfigValidate_JSON(t *testing.T) {
+	dir := t.TempDir()
+	bad := kongcue.WriteConfig(t, dir, "bad.yaml", "name: world\nbogus: 1\n")
+
+	var cli validateCLI
+	var buf bytes.Buffer
+	cli.ConfigValidate.Output = &buf
+
+	parser, err := kong.New(&cli, kongcue.Options())
+	if err != nil {
+		t.Fatalf("failed to create parser: %v", err)
+	}
+	if _, err := parser.Parse([]string{"config-validate", "--json", bad}); err == nil {
+		t.Fatal("expected validation failure")
+	}
+
+	var result struct {
+		Valid  bool
+		Files  []string
+		Errors []struct {
+			File    string
+			Line    int
+			Path    string
+			Message string
+		}
+	}
+	if err := json.Unmarshal(buf.Bytes(), &result); err != nil {
+		t.Fatalf("invalid JSON: %v\n%s", err, buf.String())
+	}
+	if result.Valid || len(result.Errors) != 1 {
+		t.Fatalf("expected one error, got:\n%s", buf.String())
+	}
+	if e := result.Errors[0]; e.File != bad || e.Line != 2 || e.Path != "bogus" {
+		t.Errorf("unexpected error: %+v", e)
+	}
+}
+
+func TestConfigValidate_DefaultsToConfigFlag(t *testing.T) {
+	dir := t.TempDir()
+	t.Chdir(dir)
+	// Broken YAML must be reported rather than failing before the command runs
+	kongcue.WriteConfig(t, dir, "config.yaml", "name: [")
+
+	var cli validateCLI
+	exited := -1
+	var stdout bytes.Buffer
+	parser, err := kong.New(&cli, kongcue.Options(),
+		kong.Writers(&stdout, &stdout),
+		kong.Exit(func(code int) {
+			exited = code
+			panic("exit")
+		}))
+	if err != nil {
+		t.Fatalf("failed to create parser: %v", err)
+	}
+
+	func() {
+		defer func() { recover() }()
+		parser.Parse([]string{"config-validate"})
+	}()
+
+	if exited != 1 {
+		t.Errorf("expected exit status 1, got %d", exited)
+	}
+	if !strings.Contains(stdout.String(), "config.yaml:1:") {
+		t.Errorf("expected parse error for config.yaml, got:\n%s", stdout.String())
+	}
+}
+
+func TestConfigValidate_Valid(t *testing.T) {
+	dir := t.TempDir()
+	good := kongcue.WriteConfig(t, dir, "good.yaml", "name: world\n")
+
+	var cli validateCLI
+	var buf bytes.Buffer
+	cli.ConfigValidate.Output = &buf
+
+	parser, err := kong.New(&cli, kongcue.Options())
+	if err != nil {
+		t.Fatalf("failed to create parser: %v", err)
+	}
+	if _, err := parser.Parse([]string{"config-validate", "--json", good}); err != nil {
+		t.Fatalf("expected valid config, got: %v", err)
+	}
+	if !strings.Contains(buf.String(), `"valid": true`) {
+		t.Errorf("expected valid result, got:\n%s", buf.String())
+	}
+}